@@ -1 +1,3 @@
 module go-infrastructure
+
+go 1.25
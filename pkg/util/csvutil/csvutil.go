@@ -4,9 +4,13 @@ import (
 	"encoding/csv"
 	"errors"
 	"os"
+	"sort"
+
+	"go-infrastructure/pkg/util/fileutil"
 )
 
 // ReadCsvFile reads a CSV file and returns the records as a slice of slices of strings.
+// The whole file is held in memory; use NewReader to stream large files.
 func ReadCsvFile(filePath string) ([][]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -65,23 +69,30 @@ func FilterCsvData(records [][]string, filterFunc func([]string) bool) [][]strin
 }
 
 // AppendToCsvFile appends new records to an existing CSV file.
+// The write is made under an exclusive lock so that concurrent appenders do not interleave.
 func AppendToCsvFile(filePath string, newRecords [][]string) error {
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
+	if _, err := os.Stat(filePath); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	for _, record := range newRecords {
-		if err := writer.Write(record); err != nil {
+	return fileutil.WithLock(filePath, func() error {
+		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
 			return err
 		}
-	}
+		defer file.Close()
 
-	return nil
+		writer := csv.NewWriter(file)
+		for _, record := range newRecords {
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		return file.Close()
+	})
 }
 
 // ReadCsvWithHeader reads a CSV file and returns a slice of maps for easy column access.
@@ -111,16 +122,20 @@ func ReadCsvWithHeader(filePath string) ([]map[string]string, error) {
 }
 
 // WriteCsvWithHeader writes data to a CSV file using a map with headers.
+// The header holds every key used in data, sorted, so the column order is stable between runs;
+// use WriteCsvWithSchema to choose the order.
 func WriteCsvWithHeader(filePath string, data []map[string]string) error {
 	if len(data) == 0 {
 		return errors.New("no data to write")
 	}
 
-	// Extract headers from the first row
-	headers := make([]string, 0, len(data[0]))
-	for header := range data[0] {
-		headers = append(headers, header)
+	keys := make(map[string]string)
+	for _, rowMap := range data {
+		for header := range rowMap {
+			keys[header] = ""
+		}
 	}
+	headers := sortedKeys(keys)
 
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -147,3 +162,13 @@ func WriteCsvWithHeader(filePath string, data []map[string]string) error {
 
 	return nil
 }
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,384 @@
+package csvutil
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// Encoding is the character encoding of a CSV file.
+type Encoding string
+
+const (
+	EncodingUTF8    Encoding = "utf-8" // the default; a byte order mark is skipped when reading
+	EncodingUTF16LE Encoding = "utf-16le"
+	EncodingUTF16BE Encoding = "utf-16be"
+	EncodingLatin1  Encoding = "latin1" // ISO-8859-1
+)
+
+// Dialect describes the format of a CSV file. The zero value is the encoding/csv default:
+// comma-separated UTF-8 with strict quoting.
+type Dialect struct {
+	Delimiter        rune // field separator; ',' when zero
+	Comment          rune // lines starting with this rune are skipped when reading; none when zero
+	LazyQuotes       bool // accept quotes in unquoted fields and unescaped quotes in quoted fields
+	TrimLeadingSpace bool // ignore leading white space in fields, as csv.Reader does
+	TrimSpace        bool // trim white space around every field when reading
+	// FieldsPerRecord is passed to csv.Reader: zero requires all records to have as many fields as the
+	// first, a positive number requires exactly that many, and -1 allows any number.
+	FieldsPerRecord int
+	Encoding        Encoding // of the input when reading; output is always UTF-8
+	UseCRLF         bool     // end written lines with \r\n
+	WriteBOM        bool     // start written files with a UTF-8 byte order mark, for Excel
+}
+
+// decode wraps r to yield UTF-8 according to the dialect's encoding.
+func (d Dialect) decode(r io.Reader) (io.Reader, error) {
+	switch d.Encoding {
+	case "", EncodingUTF8:
+		_, body, err := fileutil.DetectBOM(r)
+		return body, err
+	case EncodingUTF16LE, EncodingUTF16BE:
+		enc, body, err := fileutil.DetectBOM(r)
+		if err != nil {
+			return nil, err
+		}
+		bigEndian := d.Encoding == EncodingUTF16BE
+		if enc == fileutil.EncodingUTF16BE || enc == fileutil.EncodingUTF16LE {
+			bigEndian = enc == fileutil.EncodingUTF16BE
+		}
+		return fileutil.NewUTF16Reader(body, bigEndian), nil
+	case EncodingLatin1:
+		return fileutil.NewLatin1Reader(r), nil
+	}
+	return nil, errors.New("csvutil: unsupported encoding " + string(d.Encoding))
+}
+
+func (d Dialect) apply(r *csv.Reader) {
+	if d.Delimiter != 0 {
+		r.Comma = d.Delimiter
+	}
+	r.Comment = d.Comment
+	r.LazyQuotes = d.LazyQuotes
+	r.TrimLeadingSpace = d.TrimLeadingSpace
+	r.FieldsPerRecord = d.FieldsPerRecord
+}
+
+// NewDialectReader returns a Reader for r that parses the given dialect.
+func NewDialectReader(r io.Reader, hasHeader bool, d Dialect) (*Reader, error) {
+	decoded, err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := NewReader(decoded, hasHeader)
+	d.apply(reader.r)
+	reader.trim = d.TrimSpace
+	return reader, nil
+}
+
+// NewDialectWriter returns a Writer for w that writes the given dialect. The byte order mark,
+// if requested, is written immediately.
+func NewDialectWriter(w io.Writer, d Dialect) (*Writer, error) {
+	writer := NewWriter(w)
+	if d.Delimiter != 0 {
+		writer.w.Comma = d.Delimiter
+	}
+	writer.w.UseCRLF = d.UseCRLF
+	if d.WriteBOM {
+		if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+// ReadCsvFileDialect reads a whole CSV file in the given dialect, like ReadCsvFile.
+func ReadCsvFileDialect(filePath string, d Dialect) ([][]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := NewDialectReader(file, false, d)
+	if err != nil {
+		return nil, err
+	}
+	var records [][]string
+	for record, err := range reader.All() {
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteCsvFileDialect writes records to a CSV file in the given dialect, like WriteCsvFile.
+func WriteCsvFileDialect(filePath string, records [][]string, d Dialect) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := NewDialectWriter(file, d)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// sniffSampleSize is how much of a file SniffFile reads.
+const sniffSampleSize = 64 * 1024
+
+// sniffDelimiters are the delimiters Sniff considers, in order of preference on a tie.
+var sniffDelimiters = []rune{',', ';', '\t', '|'}
+
+// SniffResult is what Sniff detected about a CSV sample.
+type SniffResult struct {
+	Dialect   Dialect
+	HasHeader bool
+}
+
+// SniffFile detects the dialect of a CSV file from its first 64 KiB. See Sniff.
+func SniffFile(filePath string) (*SniffResult, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sample := make([]byte, sniffSampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return Sniff(sample[:n], n < len(sample))
+}
+
+// Sniff detects the encoding, delimiter, '#' comments and presence of a header from a sample of a CSV file.
+// complete reports whether the sample holds the whole file; otherwise its last, possibly cut, line is
+// ignored. The delimiter is the candidate among comma, semicolon, tab and pipe that splits the most
+// lines into the same number of fields. Lines starting with '#' are only taken for comments when
+// some of them do not split like the other lines, so that data such as "#ff0000,red" is kept. A
+// header is assumed when the first row differs in type or length from the values below it, e.g.
+// names above numbers.
+func Sniff(sample []byte, complete bool) (*SniffResult, error) {
+	d := Dialect{Encoding: sniffEncoding(sample)}
+	decoded, err := d.decode(bytes.NewReader(sample))
+	if err != nil {
+		return nil, err
+	}
+	text, err := io.ReadAll(decoded)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(text), "\r\n", "\n"), "\n")
+	if !complete && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	var nonEmpty, data, leading, inner []string // inner holds the '#' lines after the first data line
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#"):
+			if len(data) == 0 {
+				leading = append(leading, line)
+			} else {
+				inner = append(inner, line)
+			}
+		case strings.TrimSpace(line) != "":
+			data = append(data, line)
+		default:
+			continue
+		}
+		nonEmpty = append(nonEmpty, line)
+	}
+	if len(nonEmpty) == 0 {
+		return nil, errors.New("CSV file is empty")
+	}
+	if len(data) == 0 {
+		data, leading = leading, nil // a file of '#' lines is data, not comments
+	}
+
+	var delimiters int
+	d.Delimiter, delimiters = sniffDelimiter(data)
+	// '#' lines are comments when they do not split like the data, e.g. "# exported on 2024-01-02"
+	// above rows of several columns, while lines such as "#ff0000,red" are data. With a single
+	// column nothing tells them apart and leading '#' lines are taken for comments.
+	comment := delimiters == 0 && len(leading) > 0
+	for _, line := range append(leading, inner...) {
+		if countUnquoted(line, d.Delimiter) != delimiters {
+			comment = true
+		}
+	}
+	if comment {
+		d.Comment = '#'
+		nonEmpty = data
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.Join(nonEmpty, "\n")))
+	reader.Comma = d.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var rows [][]string
+	for len(rows) < 20 {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, record)
+	}
+	return &SniffResult{Dialect: d, HasHeader: sniffHeader(rows)}, nil
+}
+
+func sniffEncoding(sample []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+	// ASCII text in UTF-16 has a NUL in every other byte.
+	var evenNUL, oddNUL int
+	for i, b := range sample {
+		if b == 0 {
+			if i%2 == 0 {
+				evenNUL++
+			} else {
+				oddNUL++
+			}
+		}
+	}
+	if evenNUL+oddNUL > len(sample)/4 {
+		if evenNUL > oddNUL {
+			return EncodingUTF16BE
+		}
+		return EncodingUTF16LE
+	}
+	if utf8.Valid(trimPartialRune(sample)) {
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off at the end of a sample.
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// sniffDelimiter picks the delimiter that gives the most lines the same, non-zero number of separators,
+// counting only separators outside quotes.
+func sniffDelimiter(lines []string) (delim rune, count int) {
+	best, bestScore, bestFields := sniffDelimiters[0], 0, 0
+	for _, delim := range sniffDelimiters {
+		counts := make(map[int]int)
+		for _, line := range lines {
+			if n := countUnquoted(line, delim); n > 0 {
+				counts[n]++
+			}
+		}
+		score, fields := 0, 0
+		for n, c := range counts {
+			if c > score || (c == score && n > fields) {
+				score, fields = c, n
+			}
+		}
+		if score > bestScore || (score == bestScore && fields > bestFields) {
+			best, bestScore, bestFields = delim, score, fields
+		}
+	}
+	return best, bestFields
+}
+
+func countUnquoted(line string, delim rune) int {
+	n := 0
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delim && !quoted:
+			n++
+		}
+	}
+	return n
+}
+
+// sniffHeader votes column by column on whether the first row looks different from the rest.
+func sniffHeader(rows [][]string) bool {
+	if len(rows) < 2 {
+		return false
+	}
+	header, data := rows[0], rows[1:]
+	seen := make(map[string]bool)
+	for _, h := range header {
+		if h == "" || seen[h] {
+			return false // headers are non-empty and distinct
+		}
+		seen[h] = true
+	}
+
+	votes := 0
+	for col, h := range header {
+		allNumeric, sameLength := true, true
+		length := -1
+		for _, row := range data {
+			if col >= len(row) {
+				continue
+			}
+			if !isNumeric(row[col]) {
+				allNumeric = false
+			}
+			if length == -1 {
+				length = utf8.RuneCountInString(row[col])
+			} else if utf8.RuneCountInString(row[col]) != length {
+				sameLength = false
+			}
+		}
+		switch {
+		case allNumeric && !isNumeric(h):
+			votes++
+		case isNumeric(h) && !allNumeric:
+			votes--
+		case sameLength && length >= 0 && utf8.RuneCountInString(h) != length:
+			votes++
+		case isNumeric(h):
+			votes--
+		}
+	}
+	return votes > 0
+}
+
+// isNumeric reports whether s is a number, accepting a decimal comma as used in many locales.
+func isNumeric(s string) bool {
+	s = strings.TrimSpace(s)
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	return err == nil
+}
@@ -0,0 +1,386 @@
+package csvutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go-infrastructure/pkg/util/fileutil"
+	"go-infrastructure/pkg/util/jsonutil"
+)
+
+// JSONOptions configures the conversions between CSV and JSON.
+type JSONOptions struct {
+	// Lines writes JSON Lines, one object per line, instead of a JSON array. Input is always accepted
+	// in both forms.
+	Lines bool
+	// KeepStrings writes every CSV value as a JSON string. By default "true" and "false" become
+	// booleans, values in JSON number syntax become numbers and empty values become null.
+	KeepStrings bool
+	// FlatKeys keeps dotted CSV column names as they are. By default a column "a.b" becomes the field
+	// "b" of a nested object "a", the inverse of jsonutil.FlattenJSON.
+	FlatKeys bool
+	// Header sets the CSV columns written by JSONToCsv. When nil, the columns are the sorted union of
+	// the keys of all objects, which holds the whole input in memory; set it to stream large inputs.
+	Header []string
+}
+
+// jsonNode is a field of the JSON objects written for each CSV record: either a column or a nested
+// object built from the columns sharing a dotted prefix.
+type jsonNode struct {
+	name     string
+	column   int // -1 for nested objects
+	children []*jsonNode
+}
+
+// jsonTree arranges the header into the fields of the output objects, in column order.
+func jsonTree(header []string, flat bool) (*jsonNode, error) {
+	root := &jsonNode{column: -1}
+	for col, name := range header {
+		path := []string{name}
+		if !flat {
+			path = strings.Split(name, ".")
+		}
+		node := root
+		for i, part := range path {
+			var child *jsonNode
+			for _, c := range node.children {
+				if c.name == part {
+					child = c
+					break
+				}
+			}
+			last := i == len(path)-1
+			switch {
+			case child == nil:
+				child = &jsonNode{name: part, column: -1}
+				if last {
+					child.column = col
+				}
+				node.children = append(node.children, child)
+			case last || child.column >= 0:
+				return nil, fmt.Errorf("csvutil: column %q conflicts with another column", name)
+			}
+			node = child
+		}
+	}
+	return root, nil
+}
+
+// jsonEncoder writes CSV records as JSON objects.
+type jsonEncoder struct {
+	tree  *jsonNode
+	opts  JSONOptions
+	buf   bytes.Buffer
+	enc   *json.Encoder
+	value bytes.Buffer
+}
+
+func newJSONEncoder(header []string, opts JSONOptions) (*jsonEncoder, error) {
+	tree, err := jsonTree(header, opts.FlatKeys)
+	if err != nil {
+		return nil, err
+	}
+	e := &jsonEncoder{tree: tree, opts: opts}
+	e.enc = json.NewEncoder(&e.value)
+	e.enc.SetEscapeHTML(false)
+	return e, nil
+}
+
+// encode returns the JSON object for record. The result is valid until the next call.
+func (e *jsonEncoder) encode(record []string) ([]byte, error) {
+	e.buf.Reset()
+	if err := e.object(e.tree, record); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+func (e *jsonEncoder) object(node *jsonNode, record []string) error {
+	e.buf.WriteByte('{')
+	for i, child := range node.children {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := e.string(child.name); err != nil {
+			return err
+		}
+		e.buf.WriteByte(':')
+		if child.column < 0 {
+			if err := e.object(child, record); err != nil {
+				return err
+			}
+			continue
+		}
+		value := field(record, child.column)
+		if e.opts.KeepStrings {
+			if err := e.string(value); err != nil {
+				return err
+			}
+			continue
+		}
+		if raw := inferJSON(value); raw != "" {
+			e.buf.WriteString(raw)
+		} else if err := e.string(value); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// string writes s as a JSON string, without escaping HTML characters.
+func (e *jsonEncoder) string(s string) error {
+	e.value.Reset()
+	if err := e.enc.Encode(s); err != nil {
+		return err
+	}
+	e.buf.Write(bytes.TrimSuffix(e.value.Bytes(), []byte("\n")))
+	return nil
+}
+
+// inferJSON returns the JSON literal for a CSV value that is a boolean, a number or empty,
+// or "" for values that should be written as strings.
+func inferJSON(s string) string {
+	switch {
+	case s == "":
+		return "null"
+	case strings.EqualFold(s, "true"):
+		return "true"
+	case strings.EqualFold(s, "false"):
+		return "false"
+	}
+	// JSON number syntax rejects leading zeros, so codes such as "007" stay strings.
+	if (s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) && strings.TrimSpace(s) == s && json.Valid([]byte(s)) {
+		return s
+	}
+	return ""
+}
+
+// CsvToJSON converts CSV with a header from r to a JSON array of objects, or JSON Lines, written to w.
+// Records are converted one at a time, so input of any size is converted in constant memory.
+func CsvToJSON(w io.Writer, r *Reader, opts JSONOptions) error {
+	header, err := r.Header()
+	if err != nil {
+		return err
+	}
+	enc, err := newJSONEncoder(header, opts)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriterSize(w, writerBufferSize)
+	if !opts.Lines {
+		bw.WriteString("[")
+	}
+	for record, err := range r.All() {
+		if err != nil {
+			return err
+		}
+		obj, err := enc.encode(record)
+		if err != nil {
+			return err
+		}
+		if !opts.Lines {
+			if r.Rows() > 1 {
+				bw.WriteByte(',')
+			}
+			bw.WriteByte('\n')
+		}
+		bw.Write(obj)
+		if opts.Lines {
+			bw.WriteByte('\n')
+		}
+	}
+	if !opts.Lines {
+		if r.Rows() > 0 {
+			bw.WriteByte('\n')
+		}
+		bw.WriteString("]\n")
+	}
+	return bw.Flush()
+}
+
+// jsonObjects calls fn with each object of a JSON array or of a stream of JSON values such as
+// JSON Lines, flattened as by jsonutil.FlattenJSON. Data after the closing bracket of an array is
+// an error, as are keys that collide once flattened.
+func jsonObjects(r io.Reader, fn func(map[string]interface{}) error) error {
+	br := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			first = b
+			br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	for n := 0; ; n++ {
+		if first == '[' && !dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			if _, err := dec.Token(); err != io.EOF {
+				if err == nil {
+					err = errors.New("csvutil: unexpected data after the JSON array")
+				}
+				return err
+			}
+			return nil
+		}
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF && first != '[' {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("csvutil: JSON value %d is not an object", n+1)
+		}
+		flat, err := jsonutil.FlattenMap(obj)
+		if err != nil {
+			return fmt.Errorf("csvutil: JSON value %d: %w", n+1, err)
+		}
+		if err := fn(flat); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonCell formats a flattened JSON value as a CSV value. Strings are written as they are,
+// null as an empty value, and arrays as JSON text without HTML escaping.
+func jsonCell(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// JSONToCsv converts a JSON array of objects, or JSON Lines, from r to CSV with a header written to w.
+// Nested objects are flattened into dotted columns as by jsonutil.FlattenJSON. With opts.Header set,
+// objects are converted one at a time and keys outside the header are dropped; otherwise the input is
+// read completely to collect the columns. w is flushed when done.
+func JSONToCsv(w *Writer, r io.Reader, opts JSONOptions) error {
+	if opts.Header != nil {
+		if err := w.WriteHeader(opts.Header); err != nil {
+			return err
+		}
+		err := jsonObjects(r, func(obj map[string]interface{}) error {
+			return writeJSONRecord(w, opts.Header, obj)
+		})
+		return errors.Join(err, w.Flush())
+	}
+
+	var objects []map[string]interface{}
+	keys := make(map[string]string)
+	err := jsonObjects(r, func(obj map[string]interface{}) error {
+		objects = append(objects, obj)
+		for k := range obj {
+			keys[k] = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	header := sortedKeys(keys)
+	if err := w.WriteHeader(header); err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := writeJSONRecord(w, header, obj); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func writeJSONRecord(w *Writer, header []string, obj map[string]interface{}) error {
+	record := make([]string, len(header))
+	for i, name := range header {
+		cell, err := jsonCell(obj[name])
+		if err != nil {
+			return &FieldError{Row: w.Rows(), Column: name, Err: err}
+		}
+		record[i] = cell
+	}
+	return w.Write(record)
+}
+
+// CsvFileToJSON converts a CSV file with a header to a JSON file. See CsvToJSON.
+func CsvFileToJSON(csvPath, jsonPath string, opts JSONOptions) error {
+	src, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := fileutil.CreateAtomic(jsonPath, 0644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if err := CsvToJSON(dst, NewReader(src, true), opts); err != nil {
+		return err
+	}
+	return dst.Commit()
+}
+
+// JSONFileToCsv converts a JSON or JSON Lines file to a CSV file with a header. See JSONToCsv.
+func JSONFileToCsv(jsonPath, csvPath string, opts JSONOptions) error {
+	src, err := os.Open(jsonPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := fileutil.CreateAtomic(csvPath, 0644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if err := JSONToCsv(NewWriter(dst), src, opts); err != nil {
+		return err
+	}
+	return dst.Commit()
+}
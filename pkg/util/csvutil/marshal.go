@@ -0,0 +1,475 @@
+package csvutil
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FieldError reports a value that could not be converted to or from its struct field.
+type FieldError struct {
+	Line   int    // line of the record in the input, 1-based; 0 when marshalling
+	Row    int    // index of the record among the data rows, 0-based
+	Column string // header of the column; empty when the record itself cannot be parsed
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	where := fmt.Sprintf("row %d", e.Row)
+	if e.Line > 0 {
+		where = fmt.Sprintf("line %d", e.Line)
+	}
+	if e.Column == "" {
+		return fmt.Sprintf("csvutil: %s: %v", where, e.Err) // the record as a whole is malformed
+	}
+	if e.Value != "" {
+		return fmt.Sprintf("csvutil: %s, column %q: cannot parse %q: %v", where, e.Column, e.Value, e.Err)
+	}
+	return fmt.Sprintf("csvutil: %s, column %q: %v", where, e.Column, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	timeType            = reflect.TypeFor[time.Time]()
+	durationType        = reflect.TypeFor[time.Duration]()
+)
+
+// structField maps one CSV column to a struct field.
+type structField struct {
+	name      string
+	index     []int
+	layout    string // time layout from the `layout` tag
+	omitEmpty bool
+	tagged    bool // named by a `csv` tag
+}
+
+var structFieldsCache sync.Map // reflect.Type -> []structField
+
+// structFields returns the columns of a struct type. Exported fields are named by their `csv` tag,
+// or by the field name when untagged; `csv:"-"` skips a field, and the ",omitempty" option writes
+// zero values as empty strings. Fields of untagged embedded structs and pointers to structs are
+// promoted with the rules of encoding/json: of several fields with the same name, the shallowest
+// wins, a tagged one breaks a tie at the same depth, and the rest are ambiguous and dropped.
+// Embedded pointers to unexported struct types are ignored, since they cannot be allocated.
+func structFields(t reflect.Type) ([]structField, error) {
+	if cached, ok := structFieldsCache.Load(t); ok {
+		return cached.([]structField), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csvutil: %v is not a struct type", t)
+	}
+
+	type level struct {
+		t     reflect.Type
+		index []int
+	}
+	var fields []structField
+	depth := make(map[string]int) // shallowest depth of each name
+	visited := map[reflect.Type]bool{}
+	next := []level{{t, nil}}
+	for d := 0; len(next) > 0; d++ {
+		current := next
+		next = nil
+		embedded := make(map[reflect.Type]int) // how often each type is embedded at this depth
+		for _, l := range current {
+			embedded[l.t]++
+		}
+		for _, l := range current {
+			if visited[l.t] {
+				continue
+			}
+			visited[l.t] = true
+			for i := 0; i < l.t.NumField(); i++ {
+				f := l.t.Field(i)
+				tag := f.Tag.Get("csv")
+				if tag == "-" {
+					continue
+				}
+				idx := append(append([]int(nil), l.index...), i)
+				if f.Anonymous && tag == "" {
+					ft := f.Type
+					if ft.Kind() == reflect.Pointer && f.IsExported() {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct && ft != timeType {
+						next = append(next, level{ft, idx})
+						continue
+					}
+				}
+				if !f.IsExported() {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				tagged := name != ""
+				if !tagged {
+					name = f.Name
+				}
+				if prev, ok := depth[name]; ok && prev < d {
+					continue // hidden by a shallower field
+				}
+				depth[name] = d
+				field := structField{
+					name:      name,
+					index:     idx,
+					layout:    f.Tag.Get("layout"),
+					omitEmpty: opts == "omitempty",
+					tagged:    tagged,
+				}
+				fields = append(fields, field)
+				if embedded[l.t] > 1 {
+					// Reached through several embeddings: ambiguous, so make it lose against itself.
+					fields = append(fields, field)
+				}
+			}
+		}
+	}
+	fields = dominantFields(fields, depth)
+	structFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+// dominantFields keeps, for each name, the field at its shallowest depth if it is the only one there
+// or the only tagged one there, and drops the name otherwise. Fields stay in declaration order.
+func dominantFields(fields []structField, depth map[string]int) []structField {
+	byName := make(map[string][]int) // name -> indexes into fields at the shallowest depth
+	for i, f := range fields {
+		if len(f.index)-1 == depth[f.name] {
+			byName[f.name] = append(byName[f.name], i)
+		}
+	}
+	keep := make(map[int]bool)
+	for _, candidates := range byName {
+		if len(candidates) == 1 {
+			keep[candidates[0]] = true
+			continue
+		}
+		var tagged []int
+		for _, i := range candidates {
+			if fields[i].tagged {
+				tagged = append(tagged, i)
+			}
+		}
+		if len(tagged) == 1 {
+			keep[tagged[0]] = true
+		}
+	}
+	var result []structField
+	for i, f := range fields {
+		if keep[i] {
+			result = append(result, f)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return slices.Compare(result[i].index, result[j].index) < 0 })
+	return result
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex for paths through embedded pointers. A nil pointer on
+// the way is allocated when alloc is set, and otherwise reported as false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// rowType returns the struct type behind T, which may be a struct or a pointer to one.
+func rowType[T any]() (reflect.Type, bool) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Pointer {
+		return t.Elem(), true
+	}
+	return t, false
+}
+
+// Unmarshal reads CSV with a header row from r into a slice of T, which must be a struct or a pointer
+// to a struct. Columns are matched to fields by name, falling back to a case-insensitive match;
+// columns without a field are ignored and fields without a column are left zero.
+//
+// Supported field types are strings, bools, integers, floats, time.Time (RFC 3339 unless the field
+// has a `layout` tag such as `layout:"2006-01-02"`), time.Duration, pointers to these, and any type
+// implementing encoding.TextUnmarshaler. An empty cell leaves a pointer nil and any other field zero.
+// The first value that cannot be converted is reported as a *FieldError.
+func Unmarshal[T any](r io.Reader) ([]T, error) {
+	t, isPtr := rowType[T]()
+	fields, err := structFields(t)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := mapColumns(header, fields)
+
+	var rows []T
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		v := reflect.New(t)
+		for col, f := range columns {
+			if f == nil {
+				continue
+			}
+			field, _ := fieldByIndex(v.Elem(), f.index, true)
+			if err := decodeField(field, record[col], f); err != nil {
+				line, _ := reader.FieldPos(col)
+				return rows, &FieldError{Line: line, Row: row, Column: header[col], Value: record[col], Err: err}
+			}
+		}
+		if isPtr {
+			rows = append(rows, v.Interface().(T))
+		} else {
+			rows = append(rows, v.Elem().Interface().(T))
+		}
+	}
+}
+
+// UnmarshalFile reads a CSV file with a header row into a slice of T. See Unmarshal.
+func UnmarshalFile[T any](filePath string) ([]T, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Unmarshal[T](file)
+}
+
+// mapColumns returns, for each header column, the field it fills, or nil.
+func mapColumns(header []string, fields []structField) []*structField {
+	columns := make([]*structField, len(header))
+	for col, name := range header {
+		for i := range fields {
+			if fields[i].name == name {
+				columns[col] = &fields[i]
+				break
+			}
+		}
+		if columns[col] != nil {
+			continue
+		}
+		for i := range fields {
+			if strings.EqualFold(fields[i].name, name) {
+				columns[col] = &fields[i]
+				break
+			}
+		}
+	}
+	return columns
+}
+
+func decodeField(v reflect.Value, s string, f *structField) error {
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeField(v.Elem(), s, f)
+	}
+	if v.Type() != timeType && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if s == "" {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		layout := f.layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		tm, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// Marshal writes rows to w as CSV, preceded by a header row with the column names of T.
+// It accepts the same field types and tags as Unmarshal; nil pointers and zero times are written
+// as empty cells, as are zero values of fields tagged ",omitempty".
+func Marshal[T any](w io.Writer, rows []T) error {
+	t, _ := rowType[T]()
+	fields, err := structFields(t)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for row, r := range rows {
+		v := reflect.ValueOf(&r).Elem()
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return &FieldError{Row: row, Err: errors.New("nil row")}
+			}
+			v = v.Elem()
+		}
+		for i := range fields {
+			field, ok := fieldByIndex(v, fields[i].index, false)
+			if !ok {
+				record[i] = "" // inside a nil embedded pointer
+				continue
+			}
+			s, err := encodeField(field, &fields[i])
+			if err != nil {
+				return &FieldError{Row: row, Column: fields[i].name, Err: err}
+			}
+			record[i] = s
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// MarshalFile writes rows to a CSV file with a header row. See Marshal.
+func MarshalFile[T any](filePath string, rows []T) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := Marshal(file, rows); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func encodeField(v reflect.Value, f *structField) (string, error) {
+	if f.omitEmpty && v.IsZero() {
+		return "", nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		tm := v.Interface().(time.Time)
+		if tm.IsZero() {
+			return "", nil
+		}
+		layout := f.layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return tm.Format(layout), nil
+	}
+	if v.Type().Implements(textMarshalerType) || (v.CanAddr() && v.Addr().Type().Implements(textMarshalerType)) {
+		m, ok := v.Interface().(encoding.TextMarshaler)
+		if !ok {
+			m = v.Addr().Interface().(encoding.TextMarshaler)
+		}
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %v", v.Type())
+}
@@ -0,0 +1,793 @@
+package csvutil
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// Table is a header and a stream of records that query operations such as Select, Sort, GroupBy and
+// Join transform lazily: nothing is read until the result is iterated with All or written out.
+// Tables backed by a Reader can be iterated only once; tables from OpenTable and TableFromRecords
+// can be iterated again.
+type Table struct {
+	header  []string
+	records iter.Seq2[[]string, error]
+}
+
+// NewTable returns a Table reading from r, which must have a header.
+func NewTable(r *Reader) (*Table, error) {
+	header, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+	return &Table{header: header, records: r.All()}, nil
+}
+
+// TableFromRecords returns a Table over records, as returned by ReadCsvFile, whose first record is
+// the header.
+func TableFromRecords(records [][]string) (*Table, error) {
+	if len(records) == 0 {
+		return nil, errors.New("CSV file is empty")
+	}
+	return &Table{
+		header: records[0],
+		records: func(yield func([]string, error) bool) {
+			for _, record := range records[1:] {
+				if !yield(record, nil) {
+					return
+				}
+			}
+		},
+	}, nil
+}
+
+// OpenTable returns a Table over a CSV file with a header. The header is read immediately; the file
+// is opened again each time the table is iterated and closed when the iteration ends.
+func OpenTable(filePath string) (*Table, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	header, err := NewReader(file, true).Header()
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &Table{
+		header: header,
+		records: func(yield func([]string, error) bool) {
+			file, err := os.Open(filePath)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			defer file.Close()
+			r := NewReader(file, true)
+			if _, err := r.Header(); err != nil {
+				yield(nil, err)
+				return
+			}
+			for record, err := range r.All() {
+				if !yield(record, err) {
+					return
+				}
+			}
+		},
+	}, nil
+}
+
+// Header returns the column names.
+func (t *Table) Header() []string {
+	return t.header
+}
+
+// Column returns the index of the named column, or -1.
+func (t *Table) Column(name string) int {
+	return slices.Index(t.header, name)
+}
+
+// All returns an iterator over the records for use with range. Iteration stops after the first error,
+// which is yielded with a nil record.
+func (t *Table) All() iter.Seq2[[]string, error] {
+	return t.records
+}
+
+// Records returns the header followed by all records, in the form used by WriteCsvFile.
+func (t *Table) Records() ([][]string, error) {
+	records := [][]string{t.header}
+	for record, err := range t.records {
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Write writes the header and all records to w and flushes it.
+func (t *Table) Write(w *Writer) error {
+	if err := w.WriteHeader(t.header); err != nil {
+		return err
+	}
+	for record, err := range t.records {
+		if err != nil {
+			return err
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// WriteFile writes the table to a CSV file. The file is replaced atomically, so it may be one of
+// the table's own inputs.
+func (t *Table) WriteFile(filePath string) error {
+	file, err := fileutil.CreateAtomic(filePath, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := t.Write(NewWriter(file)); err != nil {
+		return err
+	}
+	return file.Commit()
+}
+
+// indices returns the positions of the named columns.
+func (t *Table) indices(columns []string) ([]int, error) {
+	idx := make([]int, len(columns))
+	for i, name := range columns {
+		if idx[i] = t.Column(name); idx[i] < 0 {
+			return nil, fmt.Errorf("csvutil: unknown column %q", name)
+		}
+	}
+	return idx, nil
+}
+
+// field returns record[i], or "" for records shorter than the header.
+func field(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+// rowKey encodes the values of the given columns as a map key.
+func rowKey(record []string, idx []int) string {
+	var b strings.Builder
+	for _, i := range idx {
+		v := field(record, i)
+		b.WriteString(strconv.Itoa(len(v)))
+		b.WriteByte(':')
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// mapRecords returns a table with the given header whose records are those of t passed through fn.
+// A nil record from fn drops the row.
+func (t *Table) mapRecords(header []string, fn func([]string) ([]string, error)) *Table {
+	return &Table{
+		header: header,
+		records: func(yield func([]string, error) bool) {
+			for record, err := range t.records {
+				if err == nil {
+					record, err = fn(record)
+					if record == nil && err == nil {
+						continue
+					}
+				}
+				if !yield(record, err) || err != nil {
+					return
+				}
+			}
+		},
+	}
+}
+
+// Filter returns the records for which fn returns true, like FilterCsvData.
+func (t *Table) Filter(fn func(record []string) bool) *Table {
+	return t.mapRecords(t.header, func(record []string) ([]string, error) {
+		if !fn(record) {
+			return nil, nil
+		}
+		return record, nil
+	})
+}
+
+// Select returns the named columns, in the given order.
+func (t *Table) Select(columns ...string) (*Table, error) {
+	idx, err := t.indices(columns)
+	if err != nil {
+		return nil, err
+	}
+	return t.mapRecords(slices.Clone(columns), func(record []string) ([]string, error) {
+		out := make([]string, len(idx))
+		for i, j := range idx {
+			out[i] = field(record, j)
+		}
+		return out, nil
+	}), nil
+}
+
+// Rename renames columns, mapping old names to new ones. Columns not in names keep their name.
+func (t *Table) Rename(names map[string]string) (*Table, error) {
+	header := slices.Clone(t.header)
+	for old, name := range names {
+		i := t.Column(old)
+		if i < 0 {
+			return nil, fmt.Errorf("csvutil: unknown column %q", old)
+		}
+		header[i] = name
+	}
+	seen := make(map[string]bool, len(header))
+	for _, name := range header {
+		if seen[name] {
+			return nil, fmt.Errorf("csvutil: duplicate column %q", name)
+		}
+		seen[name] = true
+	}
+	return &Table{header: header, records: t.records}, nil
+}
+
+// Dedupe drops records whose values in the given columns, or in all columns when none are given,
+// repeat those of an earlier record. Memory grows with the number of distinct keys.
+func (t *Table) Dedupe(columns ...string) (*Table, error) {
+	idx, err := t.indices(columns)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		idx = make([]int, len(t.header))
+		for i := range idx {
+			idx[i] = i
+		}
+	}
+	return &Table{
+		header: t.header,
+		records: func(yield func([]string, error) bool) {
+			seen := make(map[string]bool)
+			for record, err := range t.records {
+				if err == nil {
+					k := rowKey(record, idx)
+					if seen[k] {
+						continue
+					}
+					seen[k] = true
+				}
+				if !yield(record, err) || err != nil {
+					return
+				}
+			}
+		},
+	}, nil
+}
+
+// SortKey is a column to sort by.
+type SortKey struct {
+	Column string
+	Desc   bool
+	// Numeric compares values as numbers. Values that are not numbers sort after those that are,
+	// in string order.
+	Numeric bool
+}
+
+// SortOptions configures Sort.
+type SortOptions struct {
+	// MaxMemory is roughly how many bytes of records are sorted in memory; larger inputs are sorted
+	// in chunks of that size that are spilled to temporary files and merged. It defaults to 64 MiB.
+	MaxMemory int64
+	// TempDir is where chunks are spilled, os.TempDir() by default.
+	TempDir string
+}
+
+const defaultSortMemory = 64 << 20
+
+// compareValues compares two values as strings, or as numbers when numeric is set.
+func compareValues(a, b string, numeric bool) int {
+	if numeric {
+		x, errX := strconv.ParseFloat(strings.TrimSpace(a), 64)
+		y, errY := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		switch {
+		case errX == nil && errY == nil:
+			return cmp.Compare(x, y)
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// Sort returns the records ordered by the given keys, the first key being the most significant.
+// The sort is stable. Inputs larger than opts.MaxMemory are sorted on disk, and the temporary files
+// are removed when the iteration ends.
+func (t *Table) Sort(opts SortOptions, keys ...SortKey) (*Table, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("csvutil: no sort keys")
+	}
+	columns := make([]string, len(keys))
+	for i, k := range keys {
+		columns[i] = k.Column
+	}
+	idx, err := t.indices(columns)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxMemory <= 0 {
+		opts.MaxMemory = defaultSortMemory
+	}
+	compare := func(a, b []string) int {
+		for i, k := range keys {
+			c := compareValues(field(a, idx[i]), field(b, idx[i]), k.Numeric)
+			if k.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	return &Table{
+		header: t.header,
+		records: func(yield func([]string, error) bool) {
+			if err := externalSort(t.records, compare, opts, func(record []string) bool {
+				return yield(record, nil)
+			}); err != nil {
+				yield(nil, err)
+			}
+		},
+	}, nil
+}
+
+// externalSort passes the records of src to yield in sorted order, spilling sorted chunks to disk
+// when they exceed opts.MaxMemory. It returns early without error when yield returns false.
+func externalSort(src iter.Seq2[[]string, error], compare func(a, b []string) int, opts SortOptions, yield func([]string) bool) error {
+	var chunk [][]string
+	var size int64
+	var spills []string
+	defer func() {
+		for _, path := range spills {
+			os.Remove(path)
+		}
+	}()
+
+	for record, err := range src {
+		if err != nil {
+			return err
+		}
+		chunk = append(chunk, record)
+		size += recordSize(record)
+		if size >= opts.MaxMemory {
+			slices.SortStableFunc(chunk, compare)
+			path, err := spill(chunk, opts.TempDir)
+			if err != nil {
+				return err
+			}
+			spills = append(spills, path)
+			clear(chunk)
+			chunk, size = chunk[:0], 0
+		}
+	}
+	slices.SortStableFunc(chunk, compare)
+	if len(spills) == 0 {
+		for _, record := range chunk {
+			if !yield(record) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	// Merge the spilled chunks and the one in memory, which comes last in input order. Ties go to the
+	// earlier chunk to keep the sort stable.
+	sources := make([]func() ([]string, error), 0, len(spills)+1)
+	for _, path := range spills {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		sources = append(sources, readSpilled(bufio.NewReader(file)))
+	}
+	sources = append(sources, func() ([]string, error) {
+		if len(chunk) == 0 {
+			return nil, io.EOF
+		}
+		record := chunk[0]
+		chunk = chunk[1:]
+		return record, nil
+	})
+
+	h := &mergeHeap{compare: compare}
+	for i, next := range sources {
+		record, err := next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h.items = append(h.items, mergeItem{record, i})
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		item := h.items[0]
+		if !yield(item.record) {
+			return nil
+		}
+		record, err := sources[item.source]()
+		switch {
+		case err == io.EOF:
+			heap.Pop(h)
+		case err != nil:
+			return err
+		default:
+			h.items[0].record = record
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
+
+// recordSize estimates the memory used by a record.
+func recordSize(record []string) int64 {
+	size := int64(24 + 16*len(record))
+	for _, f := range record {
+		size += int64(len(f))
+	}
+	return size
+}
+
+// spill writes a sorted chunk to a temporary file and returns its path. Records are stored as a
+// uvarint field count followed by each field as a uvarint length and its bytes, so that every record
+// reads back exactly as written, including empty and zero-field records that CSV cannot represent.
+func spill(chunk [][]string, dir string) (string, error) {
+	file, err := os.CreateTemp(dir, "csvsort-*")
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(file)
+	var buf []byte
+	for _, record := range chunk {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(record)))
+		for _, f := range record {
+			buf = binary.AppendUvarint(buf, uint64(len(f)))
+			buf = append(buf, f...)
+		}
+		if _, err := w.Write(buf); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return "", err
+		}
+	}
+	if err := errors.Join(w.Flush(), file.Close()); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// readSpilled returns a function reading the records written by spill from r, one per call,
+// until io.EOF.
+func readSpilled(r *bufio.Reader) func() ([]string, error) {
+	return func() ([]string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err // io.EOF at the end of the file
+		}
+		record := make([]string, n)
+		for i := range record {
+			size, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			b := make([]byte, size)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, noEOF(err)
+			}
+			record[i] = string(b)
+		}
+		return record, nil
+	}
+}
+
+// noEOF turns io.EOF in the middle of a spilled record into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type mergeItem struct {
+	record []string
+	source int
+}
+
+// mergeHeap orders the current record of each sorted source, implementing heap.Interface.
+type mergeHeap struct {
+	items   []mergeItem
+	compare func(a, b []string) int
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.compare(h.items[i].record, h.items[j].record); c != 0 {
+		return c < 0
+	}
+	return h.items[i].source < h.items[j].source
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// AggFunc is an aggregate function of GroupBy.
+type AggFunc string
+
+const (
+	AggCount AggFunc = "count" // the number of rows, or of non-empty values when a column is given
+	AggSum   AggFunc = "sum"
+	AggAvg   AggFunc = "avg"
+	AggMin   AggFunc = "min" // compared as numbers when both values are numbers, as strings otherwise
+	AggMax   AggFunc = "max"
+)
+
+// Aggregate computes one output column of GroupBy. Sum and Avg skip empty values and fail on values
+// that are not numbers.
+type Aggregate struct {
+	Func   AggFunc
+	Column string
+	Name   string // of the output column; defaults to e.g. "sum_amount", or "count"
+}
+
+// Count counts the rows of each group.
+func Count() Aggregate { return Aggregate{Func: AggCount} }
+
+// Sum adds up the values of a column.
+func Sum(column string) Aggregate { return Aggregate{Func: AggSum, Column: column} }
+
+// Avg averages the values of a column.
+func Avg(column string) Aggregate { return Aggregate{Func: AggAvg, Column: column} }
+
+// Min returns the smallest value of a column.
+func Min(column string) Aggregate { return Aggregate{Func: AggMin, Column: column} }
+
+// Max returns the largest value of a column.
+func Max(column string) Aggregate { return Aggregate{Func: AggMax, Column: column} }
+
+// As names the output column.
+func (a Aggregate) As(name string) Aggregate {
+	a.Name = name
+	return a
+}
+
+func (a Aggregate) name() string {
+	switch {
+	case a.Name != "":
+		return a.Name
+	case a.Column == "":
+		return string(a.Func)
+	}
+	return string(a.Func) + "_" + a.Column
+}
+
+// aggState accumulates one aggregate of one group.
+type aggState struct {
+	count int
+	sum   float64
+	best  string
+	found bool
+}
+
+// GroupBy returns one record per distinct combination of the key columns, in order of first
+// appearance, holding the keys followed by the aggregates. Without keys the whole table is one group.
+// Memory grows with the number of groups, not the number of rows.
+func (t *Table) GroupBy(keys []string, aggs ...Aggregate) (*Table, error) {
+	keyIdx, err := t.indices(keys)
+	if err != nil {
+		return nil, err
+	}
+	aggIdx := make([]int, len(aggs))
+	header := slices.Clone(keys)
+	for i, a := range aggs {
+		aggIdx[i] = -1
+		if a.Column != "" {
+			if aggIdx[i] = t.Column(a.Column); aggIdx[i] < 0 {
+				return nil, fmt.Errorf("csvutil: unknown column %q", a.Column)
+			}
+		} else if a.Func != AggCount {
+			return nil, fmt.Errorf("csvutil: %s needs a column", a.Func)
+		}
+		header = append(header, a.name())
+	}
+
+	return &Table{
+		header: header,
+		records: func(yield func([]string, error) bool) {
+			type group struct {
+				keys   []string
+				states []aggState
+			}
+			var order []*group
+			groups := make(map[string]*group)
+			row := 0
+			for record, err := range t.records {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				k := rowKey(record, keyIdx)
+				g := groups[k]
+				if g == nil {
+					g = &group{keys: make([]string, len(keyIdx)), states: make([]aggState, len(aggs))}
+					for i, j := range keyIdx {
+						g.keys[i] = field(record, j)
+					}
+					groups[k] = g
+					order = append(order, g)
+				}
+				for i, a := range aggs {
+					if err := g.states[i].add(a.Func, record, aggIdx[i]); err != nil {
+						yield(nil, &FieldError{Row: row, Column: a.Column, Value: field(record, aggIdx[i]), Err: err})
+						return
+					}
+				}
+				row++
+			}
+			if len(keys) == 0 && len(order) == 0 {
+				order = append(order, &group{states: make([]aggState, len(aggs))})
+			}
+			for _, g := range order {
+				out := g.keys
+				for i, a := range aggs {
+					out = append(out, g.states[i].result(a.Func))
+				}
+				if !yield(out, nil) {
+					return
+				}
+			}
+		},
+	}, nil
+}
+
+func (s *aggState) add(fn AggFunc, record []string, col int) error {
+	if col < 0 {
+		s.count++
+		return nil
+	}
+	value := field(record, col)
+	if value == "" {
+		return nil
+	}
+	s.count++
+	switch fn {
+	case AggSum, AggAvg:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		s.sum += n
+	case AggMin, AggMax:
+		c := compareValues(value, s.best, true)
+		if !s.found || (fn == AggMin && c < 0) || (fn == AggMax && c > 0) {
+			s.best, s.found = value, true
+		}
+	}
+	return nil
+}
+
+func (s *aggState) result(fn AggFunc) string {
+	switch fn {
+	case AggCount:
+		return strconv.Itoa(s.count)
+	case AggSum:
+		return strconv.FormatFloat(s.sum, 'f', -1, 64)
+	case AggAvg:
+		if s.count == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(s.sum/float64(s.count), 'f', -1, 64)
+	}
+	return s.best
+}
+
+// JoinKind selects which records Join returns.
+type JoinKind int
+
+const (
+	InnerJoin JoinKind = iota // only left records with a match
+	LeftJoin                  // every left record; unmatched ones get empty right columns
+)
+
+// Join combines each record of t with every record of right that has the same values in the on
+// columns, which both tables must have. The result has t's columns followed by right's other columns;
+// use Rename first if their names clash. right is read into memory, so it should be the smaller
+// table; t is streamed.
+func (t *Table) Join(right *Table, kind JoinKind, on ...string) (*Table, error) {
+	if len(on) == 0 {
+		return nil, errors.New("csvutil: no join columns")
+	}
+	leftIdx, err := t.indices(on)
+	if err != nil {
+		return nil, err
+	}
+	rightIdx, err := right.indices(on)
+	if err != nil {
+		return nil, err
+	}
+	header := slices.Clone(t.header)
+	var rest []int // right columns that are not join keys
+	for i, name := range right.header {
+		if slices.Contains(on, name) {
+			continue
+		}
+		if slices.Contains(header, name) {
+			return nil, fmt.Errorf("csvutil: duplicate column %q", name)
+		}
+		header = append(header, name)
+		rest = append(rest, i)
+	}
+
+	return &Table{
+		header: header,
+		records: func(yield func([]string, error) bool) {
+			matches := make(map[string][][]string)
+			for record, err := range right.records {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				k := rowKey(record, rightIdx)
+				matches[k] = append(matches[k], record)
+			}
+			for record, err := range t.records {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				left := make([]string, len(t.header))
+				copy(left, record)
+				found := matches[rowKey(record, leftIdx)]
+				if len(found) == 0 && kind == LeftJoin {
+					if !yield(append(left, make([]string, len(rest))...), nil) {
+						return
+					}
+				}
+				for _, m := range found {
+					out := slices.Clip(slices.Clone(left))
+					for _, i := range rest {
+						out = append(out, field(m, i))
+					}
+					if !yield(out, nil) {
+						return
+					}
+				}
+			}
+		},
+	}, nil
+}
@@ -0,0 +1,293 @@
+package csvutil
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// ColumnType is the type of the values of a schema column.
+type ColumnType int
+
+const (
+	TypeString ColumnType = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeTime // parsed with Column.Layout, RFC 3339 by default
+)
+
+// String returns the name of the type.
+func (t ColumnType) String() string {
+	switch t {
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeTime:
+		return "time"
+	}
+	return "string"
+}
+
+// Column declares one column of a Schema.
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Required bool   // an empty value is a violation, unless Default is set
+	Default  string // used for empty or missing values
+	Layout   string // time layout for TypeTime
+}
+
+// Schema declares the columns of a CSV file, in order. Writers use it for the header and column order,
+// readers to check the header, fill in defaults and validate values.
+type Schema struct {
+	Columns []Column
+	// Strict makes columns that are not in the schema a violation, instead of passing them through
+	// when reading and dropping them when writing.
+	Strict bool
+}
+
+// ValidationError lists every value of a file or a set of rows that violates a Schema.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more violations)", e.Errors[0], len(e.Errors)-1)
+}
+
+// Unwrap returns the individual violations, so that errors.As finds a *FieldError.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Header returns the column names in order.
+func (s *Schema) Header() []string {
+	header := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		header[i] = c.Name
+	}
+	return header
+}
+
+func (s *Schema) column(name string) *Column {
+	for i := range s.Columns {
+		if s.Columns[i].Name == name {
+			return &s.Columns[i]
+		}
+	}
+	return nil
+}
+
+// check applies the default to value and validates it against the column.
+func (c *Column) check(value string) (string, error) {
+	if value == "" {
+		value = c.Default
+	}
+	if value == "" {
+		if c.Required {
+			return "", errors.New("required value is missing")
+		}
+		return "", nil
+	}
+	var err error
+	switch c.Type {
+	case TypeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case TypeBool:
+		_, err = strconv.ParseBool(value)
+	case TypeTime:
+		layout := c.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		_, err = time.Parse(layout, value)
+	}
+	if err != nil {
+		return value, fmt.Errorf("not a valid %v: %w", c.Type, err)
+	}
+	return value, nil
+}
+
+// Record converts a row keyed by column name into a record in schema order, applying defaults.
+// Violations are returned as a *ValidationError with Row set to row.
+func (s *Schema) Record(row int, m map[string]string) ([]string, error) {
+	var errs []*FieldError
+	if s.Strict {
+		for _, name := range sortedKeys(m) {
+			if s.column(name) == nil {
+				errs = append(errs, &FieldError{Row: row, Column: name, Value: m[name], Err: errors.New("column is not in the schema")})
+			}
+		}
+	}
+	record := make([]string, len(s.Columns))
+	for i := range s.Columns {
+		c := &s.Columns[i]
+		value, err := c.check(m[c.Name])
+		if err != nil {
+			errs = append(errs, &FieldError{Row: row, Column: c.Name, Value: m[c.Name], Err: err})
+		}
+		record[i] = value
+	}
+	if errs != nil {
+		return record, &ValidationError{Errors: errs}
+	}
+	return record, nil
+}
+
+// validateHeader checks that a file header has every required column, and no unknown ones when strict.
+func (s *Schema) validateHeader(header []string) []*FieldError {
+	var errs []*FieldError
+	present := make(map[string]bool, len(header))
+	for _, name := range header {
+		present[name] = true
+		if s.Strict && s.column(name) == nil {
+			errs = append(errs, &FieldError{Line: 1, Row: -1, Column: name, Err: errors.New("column is not in the schema")})
+		}
+	}
+	for _, c := range s.Columns {
+		if c.Required && c.Default == "" && !present[c.Name] {
+			errs = append(errs, &FieldError{Line: 1, Row: -1, Column: c.Name, Err: errors.New("required column is missing")})
+		}
+	}
+	return errs
+}
+
+// read reads CSV with a header from r, calling fn for every row with defaults applied,
+// and returns all violations. Malformed rows are violations too and are not passed to fn.
+func (s *Schema) read(r io.Reader, fn func(map[string]string)) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return err
+	}
+	errs := s.validateHeader(header)
+	columns := make([]*Column, len(header))
+	for i, name := range header {
+		columns[i] = s.column(name)
+	}
+
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			errs = append(errs, &FieldError{Line: parseErr.StartLine, Row: row, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			if errs != nil {
+				return errors.Join(err, &ValidationError{Errors: errs})
+			}
+			return err
+		}
+		m := make(map[string]string, len(s.Columns))
+		for i, value := range record {
+			c := columns[i]
+			if c == nil {
+				if !s.Strict {
+					m[header[i]] = value
+				}
+				continue
+			}
+			checked, err := c.check(value)
+			if err != nil {
+				line, _ := reader.FieldPos(i)
+				errs = append(errs, &FieldError{Line: line, Row: row, Column: c.Name, Value: value, Err: err})
+			}
+			m[c.Name] = checked
+		}
+		// Columns missing from the file take their defaults.
+		for _, c := range s.Columns {
+			if _, ok := m[c.Name]; !ok && c.Default != "" {
+				m[c.Name] = c.Default
+			}
+		}
+		if fn != nil {
+			fn(m)
+		}
+	}
+	if errs != nil {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Validate checks CSV with a header from r against the schema and reports every violation, malformed
+// rows included, as a *ValidationError. The input is streamed, so memory grows with the number of
+// violations rather than with the size of the input. If reading fails, the error is returned joined
+// with the violations found so far.
+func (s *Schema) Validate(r io.Reader) error {
+	return s.read(r, nil)
+}
+
+// ReadCsvWithSchema reads a CSV file with a header, filling in defaults from the schema. All rows that
+// can be parsed are returned; if any violate the schema or are malformed, a *ValidationError listing
+// every violation is returned too.
+func ReadCsvWithSchema(filePath string, schema *Schema) ([]map[string]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows []map[string]string
+	err = schema.read(file, func(m map[string]string) {
+		rows = append(rows, m)
+	})
+	return rows, err
+}
+
+// WriteCsvWithSchema writes data to a CSV file with the schema's header and column order. The rows are
+// validated first; if any violate the schema, nothing is written and a *ValidationError listing every
+// violation is returned.
+func WriteCsvWithSchema(filePath string, schema *Schema, data []map[string]string) error {
+	records := make([][]string, 0, len(data)+1)
+	records = append(records, schema.Header())
+	var errs []*FieldError
+	for row, m := range data {
+		record, err := schema.Record(row, m)
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			errs = append(errs, verr.Errors...)
+		}
+		records = append(records, record)
+	}
+	if errs != nil {
+		return &ValidationError{Errors: errs}
+	}
+
+	file, err := fileutil.CreateAtomic(filePath, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := csv.NewWriter(file).WriteAll(records); err != nil {
+		return err
+	}
+	return file.Commit()
+}
@@ -0,0 +1,333 @@
+package csvutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"iter"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ErrNoHeader is returned by operations that need a header when none was read or written.
+var ErrNoHeader = errors.New("csvutil: no header")
+
+// Reader reads CSV records one at a time, so that files of any size can be processed in constant memory.
+type Reader struct {
+	r         *csv.Reader
+	hasHeader bool
+	header    []string
+	started   bool
+	rows      int
+	trim      bool // trim white space around fields, see Dialect.TrimSpace
+}
+
+// NewReader returns a Reader for r. If hasHeader is set, the first record is taken as the header
+// and is not returned by Next.
+func NewReader(r io.Reader, hasHeader bool) *Reader {
+	return &Reader{r: csv.NewReader(r), hasHeader: hasHeader}
+}
+
+// CSV returns the underlying csv.Reader, e.g. to set Comma or FieldsPerRecord before the first read.
+func (r *Reader) CSV() *csv.Reader {
+	return r.r
+}
+
+// Header returns the header, reading it if no record has been read yet. It returns ErrNoHeader
+// for readers created without one.
+func (r *Reader) Header() ([]string, error) {
+	if !r.hasHeader {
+		return nil, ErrNoHeader
+	}
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+	return r.header, nil
+}
+
+func (r *Reader) start() error {
+	if r.started {
+		return nil
+	}
+	r.started = true
+	if !r.hasHeader {
+		return nil
+	}
+	header, err := r.r.Read()
+	if err == io.EOF {
+		return errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return err
+	}
+	r.header = r.trimmed(header)
+	return nil
+}
+
+func (r *Reader) trimmed(record []string) []string {
+	if r.trim {
+		for i, field := range record {
+			record[i] = strings.TrimSpace(field)
+		}
+	}
+	return record
+}
+
+// Next returns the next data record, or io.EOF after the last one.
+func (r *Reader) Next() ([]string, error) {
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.rows++
+	return r.trimmed(record), nil
+}
+
+// NextMap returns the next data record keyed by the header. Records with a different number of
+// fields than the header are reported as errors rather than skipped.
+func (r *Reader) NextMap() (map[string]string, error) {
+	header, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+	record, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	if len(record) != len(header) {
+		line, _ := r.r.FieldPos(0)
+		return nil, &csv.ParseError{StartLine: line, Line: line, Err: csv.ErrFieldCount}
+	}
+	m := make(map[string]string, len(header))
+	for i, name := range header {
+		m[name] = record[i]
+	}
+	return m, nil
+}
+
+// All returns an iterator over the remaining data records for use with range. Iteration stops after
+// the first error, which is yielded with a nil record.
+func (r *Reader) All() iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		for {
+			record, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(record, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Rows returns the number of data records read so far.
+func (r *Reader) Rows() int {
+	return r.rows
+}
+
+// Line returns the input line on which the last record read started.
+func (r *Reader) Line() int {
+	line, _ := r.r.FieldPos(0)
+	return line
+}
+
+// Writer writes CSV records through a buffer. Call Flush when done.
+type Writer struct {
+	w      *csv.Writer
+	header []string
+	rows   int
+}
+
+// writerBufferSize is the buffer used by Writer, larger than csv.Writer's default for throughput.
+const writerBufferSize = 64 * 1024
+
+// NewWriter returns a Writer for w.
+func NewWriter(w io.Writer) *Writer {
+	// csv.NewWriter uses a bufio.Writer as it is when it is large enough.
+	return &Writer{w: csv.NewWriter(bufio.NewWriterSize(w, writerBufferSize))}
+}
+
+// CSV returns the underlying csv.Writer, e.g. to set Comma or UseCRLF before the first write.
+func (w *Writer) CSV() *csv.Writer {
+	return w.w
+}
+
+// WriteHeader writes the header record. It must come before any data record.
+func (w *Writer) WriteHeader(header []string) error {
+	if w.header != nil || w.rows > 0 {
+		return errors.New("csvutil: header must be written first and only once")
+	}
+	if err := w.w.Write(header); err != nil {
+		return err
+	}
+	w.header = append([]string(nil), header...)
+	return nil
+}
+
+// Write writes a data record.
+func (w *Writer) Write(record []string) error {
+	if err := w.w.Write(record); err != nil {
+		return err
+	}
+	w.rows++
+	return nil
+}
+
+// WriteMap writes a data record in the order of the header; missing keys are written as empty cells.
+func (w *Writer) WriteMap(m map[string]string) error {
+	if w.header == nil {
+		return ErrNoHeader
+	}
+	record := make([]string, len(w.header))
+	for i, name := range w.header {
+		record[i] = m[name]
+	}
+	return w.Write(record)
+}
+
+// Rows returns the number of data records written so far.
+func (w *Writer) Rows() int {
+	return w.rows
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// TransformOptions configures Transform.
+type TransformOptions struct {
+	// Workers is the number of records processed concurrently. It defaults to GOMAXPROCS.
+	Workers int
+	// Header is written before the output records. When nil, the reader's header, if any, is copied.
+	Header []string
+}
+
+// TransformFunc maps a data record to an output record. row is the 0-based index of the record among
+// the data records. Returning a nil record drops the row.
+type TransformFunc func(row int, record []string) ([]string, error)
+
+// Transform reads every record from r, passes it to fn on a pool of workers and writes the results
+// to w in input order. Memory use is bounded by the number of workers, not the size of the input.
+// The first error from reading, fn or writing stops the transform; w is flushed either way.
+func Transform(ctx context.Context, r *Reader, w *Writer, opts TransformOptions, fn TransformFunc) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	header := opts.Header
+	if header == nil && r.hasHeader {
+		var err error
+		if header, err = r.Header(); err != nil {
+			return err
+		}
+	}
+	if header != nil {
+		if err := w.WriteHeader(header); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	type numbered struct {
+		row    int
+		record []string
+	}
+	jobs := make(chan numbered)
+	results := make(chan numbered)
+	// Tokens bound the records in flight, including finished ones waiting for an earlier row.
+	tokens := make(chan struct{}, 4*workers)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for row := 0; ; row++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			record, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				cancel(err)
+				return
+			}
+			select {
+			case jobs <- numbered{row, record}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var workerWG sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerWG.Add(1)
+		go func() {
+			defer workerWG.Done()
+			for j := range jobs {
+				out, err := fn(j.row, j.record)
+				if err != nil {
+					cancel(err)
+					return
+				}
+				select {
+				case results <- numbered{j.row, out}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workerWG.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int][]string)
+	next := 0
+	for res := range results {
+		if ctx.Err() != nil {
+			continue // drain until the workers have stopped
+		}
+		pending[res.row] = res.record
+		for {
+			record, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-tokens
+			if record == nil {
+				continue
+			}
+			if err := w.Write(record); err != nil {
+				cancel(err)
+				break
+			}
+		}
+	}
+	wg.Wait()
+
+	flushErr := w.Flush()
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	return flushErr
+}
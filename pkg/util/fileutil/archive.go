@@ -2,9 +2,10 @@ package fileutil
 
 import (
 	"archive/zip"
+	"bytes"
+	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 )
 
 // ZipFiles compresses one or more files into a single zip archive file.
@@ -58,45 +59,150 @@ func addFileToZip(zipWriter *zip.Writer, filename string) error {
 }
 
 // Unzip extracts a zip archive, specified by the src parameter, to a destination directory specified by the dest parameter.
+// Entries are validated against DefaultExtractLimits; use UnzipWithLimits to tune them.
 func Unzip(src, dest string) ([]string, error) {
-	var filenames []string
+	return UnzipWithLimits(src, dest, DefaultExtractLimits())
+}
 
+// UnzipWithLimits extracts a zip archive into dest, rejecting entries that would escape dest
+// and archives that exceed the given limits. The returned error is an *ExtractError when a rule is violated.
+func UnzipWithLimits(src, dest string, limits ExtractLimits) ([]string, error) {
 	r, err := zip.OpenReader(src)
 	if err != nil {
-		return filenames, err
+		return nil, err
 	}
 	defer r.Close()
 
+	x, err := newExtractor(dest, limits)
+	if err != nil {
+		return nil, err
+	}
+	return unzip(x, &r.Reader)
+}
+
+// unzip extracts every entry of r through x.
+func unzip(x *extractor, r *zip.Reader) ([]string, error) {
+	var filenames []string
 	for _, f := range r.File {
-		fpath := filepath.Join(dest, f.Name)
-		filenames = append(filenames, fpath)
-
-		if f.FileInfo().IsDir() {
-			// Make Folder
-			os.MkdirAll(fpath, os.ModePerm)
-		} else {
-			// Make File
-			if err = unzipFile(f, fpath); err != nil {
-				return filenames, err
-			}
+		if err := x.addEntry(f.Name); err != nil {
+			return filenames, err
+		}
+		if err := x.checkRatio(f.Name, int64(f.UncompressedSize64), int64(f.CompressedSize64)); err != nil {
+			return filenames, err
 		}
+
+		fpath, err := x.resolve(f.Name)
+		if err != nil {
+			return filenames, err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.mkdir(fpath, mode)
+		case mode&os.ModeSymlink != 0:
+			err = unzipSymlink(x, f, fpath)
+		case mode.IsRegular():
+			err = unzipFile(x, f, fpath)
+		default:
+			err = &ExtractError{Rule: RuleUnsupportedEntry, Entry: f.Name, Detail: mode.String()}
+		}
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, x.display(fpath))
+		x.entryDone(f.Name)
 	}
 	return filenames, nil
 }
 
-func unzipFile(f *zip.File, filepath string) error {
-	file, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+func unzipFile(x *extractor, f *zip.File, fpath string) error {
+	rc, err := f.Open()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer rc.Close()
 
+	return x.writeFile(f.Name, fpath, rc, f.Mode(), int64(f.CompressedSize64))
+}
+
+func unzipSymlink(x *extractor, f *zip.File, fpath string) error {
 	rc, err := f.Open()
 	if err != nil {
 		return err
 	}
 	defer rc.Close()
 
-	_, err = io.Copy(file, rc)
-	return err
+	// The entry content is the link target; anything longer than a path is not a real link.
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return x.symlink(f.Name, fpath, string(target))
+}
+
+// ArchiveFormat identifies the container format of an archive file.
+type ArchiveFormat string
+
+// Archive formats recognised by DetectArchiveFormat.
+const (
+	FormatUnknown ArchiveFormat = ""
+	FormatZip     ArchiveFormat = "zip"
+	FormatTar     ArchiveFormat = "tar"
+	FormatTarGz   ArchiveFormat = "tar.gz"
+	FormatZstd    ArchiveFormat = "zst"
+)
+
+// DetectArchiveFormat sniffs the format of the archive at path from its magic bytes.
+// A gzip stream is assumed to contain a tar archive.
+func DetectArchiveFormat(path string) (ArchiveFormat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatUnknown, err
+	}
+	return detectArchiveFormat(header[:n]), nil
+}
+
+func detectArchiveFormat(header []byte) ArchiveFormat {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatTarGz
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return FormatZstd
+	case len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar")):
+		return FormatTar
+	}
+	return FormatUnknown
+}
+
+// Extract extracts a zip, tar or tar.gz archive into dest, detecting the format from its content
+// rather than its file extension. Entries are validated against DefaultExtractLimits.
+func Extract(path, dest string) ([]string, error) {
+	return ExtractWithLimits(path, dest, DefaultExtractLimits())
+}
+
+// ExtractWithLimits is like Extract but validates entries against the given limits.
+func ExtractWithLimits(path, dest string, limits ExtractLimits) ([]string, error) {
+	format, err := DetectArchiveFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatZip:
+		return UnzipWithLimits(path, dest, limits)
+	case FormatTar, FormatTarGz:
+		return UntarWithLimits(path, dest, limits)
+	case FormatZstd:
+		return nil, fmt.Errorf("fileutil: %s: zstd archives are not supported", path)
+	}
+	return nil, fmt.Errorf("fileutil: %s: unrecognised archive format", path)
 }
@@ -0,0 +1,167 @@
+package fileutil
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// ArchiveOptions controls how ArchiveDir builds an archive.
+type ArchiveOptions struct {
+	// Format is the archive format to produce: FormatZip (the default), FormatTar or FormatTarGz.
+	Format ArchiveFormat
+	// Include, when non-empty, limits the archive to files matching at least one of these patterns.
+	// Patterns are matched against slash-separated paths relative to root; see MatchGlob.
+	// Patterns without a slash are also matched against the base name.
+	Include []string
+	// Exclude skips files and directories matching any of these patterns.
+	Exclude []string
+	// IgnoreFile names a .gitignore-style file inside root, e.g. ".gitignore", whose rules are applied.
+	IgnoreFile string
+	// ModTime, when non-zero, is used as the modification time of every entry so that archives
+	// of identical trees are byte-for-byte identical.
+	ModTime time.Time
+}
+
+// ArchiveDir archives the directory tree under root into the file out, storing paths relative to root.
+// If out lies inside root it is left out of the archive.
+func ArchiveDir(root, out string, opts ArchiveOptions) error {
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = archiveDir(file, root, opts, out); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ArchiveDirTo archives the directory tree under root into w, storing paths relative to root.
+func ArchiveDirTo(w io.Writer, root string, opts ArchiveOptions) error {
+	return archiveDir(w, root, opts, "")
+}
+
+func archiveDir(w io.Writer, root string, opts ArchiveOptions, skip string) error {
+	root = filepath.Clean(root)
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrSourceNotDirectory(root)
+	}
+
+	var ignore *IgnorePatterns
+	if opts.IgnoreFile != "" {
+		ignore, err = ReadIgnoreFile(filepath.Join(root, opts.IgnoreFile))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if skip != "" {
+		if skip, err = filepath.Abs(skip); err != nil {
+			return err
+		}
+	}
+
+	aw, err := newArchiveWriter(w, opts.Format)
+	if err != nil {
+		return err
+	}
+
+	written := make(map[string]bool)
+	// addDirs writes entries for the parent directories of rel that are not yet in the archive.
+	var addDirs func(rel string) error
+	addDirs = func(rel string) error {
+		dir := path.Dir(rel)
+		if dir == "." || written[dir] {
+			return nil
+		}
+		if err := addDirs(dir); err != nil {
+			return err
+		}
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil {
+			return err
+		}
+		written[dir] = true
+		return aw.add(newArchiveEntry(dir, info, "", opts.ModTime))
+	}
+
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if skip != "" {
+			if abs, err := filepath.Abs(p); err == nil && abs == skip {
+				return nil
+			}
+		}
+		if matchAnyGlob(opts.Exclude, rel) || ignore.Match(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			// With an include filter, directories only appear as parents of included files.
+			if len(opts.Include) > 0 || written[rel] {
+				return nil
+			}
+			if err := addDirs(rel); err != nil {
+				return err
+			}
+			written[rel] = true
+			return aw.add(newArchiveEntry(rel, info, "", opts.ModTime))
+		}
+
+		if len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel) {
+			return nil
+		}
+		if err := addDirs(rel); err != nil {
+			return err
+		}
+		return addPathToArchive(aw, p, rel, info, opts.ModTime)
+	})
+	if err != nil {
+		return err
+	}
+	return aw.close()
+}
+
+// addPathToArchive writes the file or symlink at p to aw under the name rel.
+func addPathToArchive(aw archiveWriter, p, rel string, info os.FileInfo, modTime time.Time) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		return aw.add(newArchiveEntry(rel, info, link, modTime))
+	}
+	if !info.Mode().IsRegular() {
+		return nil // sockets, devices and pipes are not archived
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	entry := newArchiveEntry(rel, info, "", modTime)
+	entry.Body = file
+	return aw.add(entry)
+}
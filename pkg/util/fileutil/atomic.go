@@ -0,0 +1,152 @@
+package fileutil
+
+import (
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// WriteOption configures the file writing helpers such as WriteFile, WriteBinaryFile and WriteLines.
+type WriteOption func(*writeConfig)
+
+type writeConfig struct {
+	atomic bool
+	perm   os.FileMode
+}
+
+// Atomic makes a write helper replace the file atomically: data goes to a temporary file in the same
+// directory, which is synced and renamed over the target, so readers and crashes never observe a partial file.
+// The permissions of an existing target are preserved.
+func Atomic() WriteOption {
+	return func(c *writeConfig) {
+		c.atomic = true
+	}
+}
+
+// WithPerm sets the permissions used when the helper creates a new file.
+func WithPerm(perm os.FileMode) WriteOption {
+	return func(c *writeConfig) {
+		c.perm = perm
+	}
+}
+
+func newWriteConfig(opts []WriteOption) writeConfig {
+	c := writeConfig{perm: 0644}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// createForWrite opens filename for writing according to c. Once all data has been written, commit
+// must be called to make it visible; abort discards an atomic write and just closes an in-place one.
+func createForWrite(filename string, c writeConfig) (file *os.File, commit func() error, abort func() error, err error) {
+	if !c.atomic {
+		file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, c.perm)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return file, file.Close, file.Close, nil
+	}
+	af, err := CreateAtomic(filename, c.perm)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return af.File, af.Commit, af.Abort, nil
+}
+
+// AtomicFile is a temporary file that replaces its target path only when committed.
+type AtomicFile struct {
+	*os.File
+	path  string
+	perm  os.FileMode
+	chmod bool // whether Commit must apply perm, to preserve the mode of an existing target
+	done  bool
+}
+
+// CreateAtomic creates a temporary file next to path that will replace path when Commit is called.
+// If path already exists its permissions are kept, otherwise perm is used, subject to the umask
+// just like os.OpenFile.
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	chmod := false
+	if info, err := os.Stat(path); err == nil {
+		perm, chmod = info.Mode().Perm(), true
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := createTemp(dir, "."+base+".tmp", perm)
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: file, path: path, perm: perm, chmod: chmod}, nil
+}
+
+// createTemp creates a new file in dir named prefix plus a random suffix. Unlike os.CreateTemp,
+// which always uses 0600, it creates the file with perm, so the umask applies as for any new file.
+func createTemp(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return file, err
+	}
+}
+
+// Commit flushes the data to disk and atomically renames the temporary file over the target path,
+// then syncs the directory so the rename itself survives a crash.
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return os.ErrClosed
+	}
+	f.done = true
+
+	err := f.File.Sync()
+	if err == nil && f.chmod {
+		err = f.File.Chmod(f.perm)
+	}
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.File.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+// Abort discards the temporary file, leaving the target untouched. It is a no-op after Commit.
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// Close aborts the write unless it has been committed, so that a deferred Close cleans up after errors.
+func (f *AtomicFile) Close() error {
+	return f.Abort()
+}
+
+// WriteFileAtomic atomically replaces filename with data. See Atomic for the guarantees.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(filename, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package fileutil
+
+import "os"
+
+// syncDir fsyncs a directory so that entries created or renamed in it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package fileutil
+
+// syncDir does nothing: Windows cannot flush a directory handle, and NTFS journals renames itself.
+func syncDir(dir string) error {
+	return nil
+}
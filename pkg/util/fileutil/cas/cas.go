@@ -0,0 +1,293 @@
+// Package cas implements a content-addressable blob store on the local filesystem.
+//
+// Blobs are addressed by the lowercase hex SHA-256 digest of their content, the same value
+// fileutil.CalculateFileSHA256 returns, and stored under sharded directories such as
+// blobs/ab/cd/abcd.... Storing the same content twice keeps a single copy.
+package cas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a blob is not in the store.
+	ErrNotFound = errors.New("cas: blob not found")
+	// ErrInvalidDigest is returned for strings that are not SHA-256 hex digests.
+	ErrInvalidDigest = errors.New("cas: invalid digest")
+	// ErrCorrupt is returned when a blob's content no longer matches its digest.
+	ErrCorrupt = errors.New("cas: blob content does not match digest")
+)
+
+// Store is a content-addressable blob store.
+type Store interface {
+	// Put stores the content read from r and returns its digest.
+	Put(r io.Reader) (string, error)
+	// Get opens the blob with the given digest. Reading it to the end verifies its integrity.
+	Get(digest string) (io.ReadCloser, error)
+	// Has reports whether the blob is in the store.
+	Has(digest string) (bool, error)
+	// Delete removes the blob from the store.
+	Delete(digest string) error
+}
+
+// FileStore is a Store backed by a directory.
+type FileStore struct {
+	root string
+}
+
+var _ Store = (*FileStore)(nil)
+
+// New opens, creating it if needed, a FileStore rooted at dir.
+func New(dir string) (*FileStore, error) {
+	s := &FileStore{root: dir}
+	for _, d := range []string{s.blobDir(), s.tmpDir()} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) blobDir() string {
+	return filepath.Join(s.root, "blobs")
+}
+
+func (s *FileStore) tmpDir() string {
+	return filepath.Join(s.root, "tmp")
+}
+
+// Path returns the file holding the blob with the given digest.
+func (s *FileStore) Path(digest string) (string, error) {
+	if !ValidDigest(digest) {
+		return "", ErrInvalidDigest
+	}
+	return filepath.Join(s.blobDir(), digest[0:2], digest[2:4], digest), nil
+}
+
+// Put streams r into the store and returns the SHA-256 digest of its content. The data is written to a
+// temporary file first, so a failed or interrupted Put never leaves a partial blob behind.
+func (s *FileStore) Put(r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(s.tmpDir(), "put-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	target, _ := s.Path(digest)
+	if _, err := os.Stat(target); err == nil {
+		// Deduplicated: refresh the time so a concurrent GC grace period covers this Put.
+		now := time.Now()
+		return digest, os.Chtimes(target, now, now)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// PutFile stores the content of the file at path.
+func (s *FileStore) PutFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return s.Put(file)
+}
+
+// Get opens the blob with the given digest. The content is hashed as it is read and the final Read
+// returns ErrCorrupt instead of io.EOF if it does not match the digest.
+func (s *FileStore) Get(digest string) (io.ReadCloser, error) {
+	target, err := s.Path(digest)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{file: file, hash: sha256.New(), digest: digest}, nil
+}
+
+// Has reports whether the blob is in the store.
+func (s *FileStore) Has(digest string) (bool, error) {
+	target, err := s.Path(digest)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Size returns the size in bytes of the blob.
+func (s *FileStore) Size(digest string) (int64, error) {
+	target, err := s.Path(digest)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Delete removes the blob from the store.
+func (s *FileStore) Delete(digest string) error {
+	target, err := s.Path(digest)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(target)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Verify rehashes the blob and returns ErrCorrupt if its content no longer matches the digest.
+func (s *FileStore) Verify(digest string) error {
+	rc, err := s.Get(digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// Walk calls fn with the digest and size of every blob in the store.
+func (s *FileStore) Walk(fn func(digest string, size int64) error) error {
+	return filepath.Walk(s.blobDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !ValidDigest(info.Name()) {
+			return nil
+		}
+		return fn(info.Name(), info.Size())
+	})
+}
+
+// GCResult summarises a garbage collection run.
+type GCResult struct {
+	Removed []string // digests of the blobs removed, or that would be removed in a dry run
+	Freed   int64    // bytes reclaimed
+}
+
+// GCOptions configures GC.
+type GCOptions struct {
+	// GracePeriod protects blobs written or re-put more recently than this, so that content stored by
+	// a concurrent writer that has not yet recorded a reference to it is not collected.
+	GracePeriod time.Duration
+	// DryRun reports what would be removed without removing anything.
+	DryRun bool
+}
+
+// GC performs the sweep phase of a mark-and-sweep collection: the caller marks the blobs still in use
+// through live, and every other blob older than the grace period is removed. Stale temporary files
+// from interrupted Puts are cleaned up as well.
+func (s *FileStore) GC(live func(digest string) bool, opts GCOptions) (GCResult, error) {
+	var result GCResult
+	cutoff := time.Now().Add(-opts.GracePeriod)
+
+	err := filepath.Walk(s.blobDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !ValidDigest(info.Name()) {
+			return nil
+		}
+		if live(info.Name()) || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if !opts.DryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		result.Removed = append(result.Removed, info.Name())
+		result.Freed += info.Size()
+		return nil
+	})
+	if err != nil || opts.DryRun {
+		return result, err
+	}
+
+	entries, err := os.ReadDir(s.tmpDir())
+	if err != nil {
+		return result, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(s.tmpDir(), entry.Name()))
+		}
+	}
+	return result, nil
+}
+
+// ValidDigest reports whether digest is a lowercase hex SHA-256 digest.
+func ValidDigest(digest string) bool {
+	if len(digest) != sha256.Size*2 {
+		return false
+	}
+	return strings.Trim(digest, "0123456789abcdef") == ""
+}
+
+// verifyingReader hashes a blob while it is read and checks the digest at EOF.
+type verifyingReader struct {
+	file   *os.File
+	hash   hash.Hash
+	digest string
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.file.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.digest {
+		return n, ErrCorrupt
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,439 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrPatchConflict is returned when a hunk does not match the lines it is applied to.
+var ErrPatchConflict = errors.New("fileutil: patch does not apply")
+
+// DiffLine is one line of a diff. Kind is ' ' for context, '-' for a removed and '+' for an added line.
+type DiffLine struct {
+	Kind byte
+	Text string
+}
+
+// Hunk is a group of changes with surrounding context, as in a unified diff.
+// Start lines are 1-based; for an empty range they name the line after which the change goes.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []DiffLine
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// DiffLines computes the line diff between a and b with the Myers algorithm and groups it into hunks
+// with the given number of context lines. It returns nil when a and b are equal.
+func DiffLines(a, b []string, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+	return groupHunks(myersDiff(a, b), context)
+}
+
+// UnifiedDiff renders the diff between a and b in unified diff format with the given number of context lines.
+// It returns an empty string when a and b are equal.
+func UnifiedDiff(oldName, newName string, a, b []string, context int) string {
+	hunks := DiffLines(a, b, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		sb.WriteString(h.Header())
+		sb.WriteByte('\n')
+		for _, line := range h.Lines {
+			sb.WriteByte(line.Kind)
+			sb.WriteString(line.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// UnifiedDiffFiles returns the unified diff between two text files.
+func UnifiedDiffFiles(file1Path, file2Path string, context int) (string, error) {
+	a, err := ReadLines(file1Path)
+	if err != nil {
+		return "", err
+	}
+	b, err := ReadLines(file2Path)
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(file1Path, file2Path, a, b, context), nil
+}
+
+// myersDiff returns the shortest edit script turning a into b.
+func myersDiff(a, b []string) []DiffLine {
+	// Common prefixes and suffixes are cheap to strip and keep the search small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	script := make([]DiffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		script = append(script, DiffLine{' ', line})
+	}
+	script = append(script, myersMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		script = append(script, DiffLine{' ', line})
+	}
+	return script
+}
+
+// myersMiddle runs the greedy Myers search. The trace keeps, for each edit distance d, the
+// furthest reaching x of every diagonal k in [-d, d], which is enough to backtrack the path.
+func myersMiddle(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	offset := n + m
+	v := make([]int, 2*(n+m)+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var reversed []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d] // furthest x per diagonal after d-1 edits, indexed by k+d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffLine{'+', b[y-1]})
+			y--
+		} else {
+			reversed = append(reversed, DiffLine{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffLine{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	script := make([]DiffLine, len(reversed))
+	for i, line := range reversed {
+		script[len(reversed)-1-i] = line
+	}
+	return script
+}
+
+// groupHunks splits an edit script into hunks, merging changes separated by at most 2*context equal lines.
+func groupHunks(script []DiffLine, context int) []Hunk {
+	var hunks []Hunk
+	oldLine, newLine := 0, 0 // lines consumed before script[i]
+	i := 0
+	for i < len(script) {
+		if script[i].Kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Back up over leading context.
+		start := i
+		for start > 0 && i-start < context && script[start-1].Kind == ' ' {
+			start--
+		}
+		h := Hunk{OldStart: oldLine - (i - start), NewStart: newLine - (i - start)}
+
+		// Extend until a run of equal lines longer than 2*context, or the end.
+		end := i
+		for end < len(script) {
+			if script[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].Kind == ' ' {
+				run++
+			}
+			if run == len(script) || run-end > 2*context {
+				if run-end > context {
+					end += context
+				} else {
+					end = run
+				}
+				break
+			}
+			end = run
+		}
+
+		h.Lines = append([]DiffLine(nil), script[start:end]...)
+		for _, line := range h.Lines {
+			if line.Kind != '+' {
+				h.OldLines++
+			}
+			if line.Kind != '-' {
+				h.NewLines++
+			}
+		}
+		for _, line := range script[i:end] {
+			if line.Kind != '+' {
+				oldLine++
+			}
+			if line.Kind != '-' {
+				newLine++
+			}
+		}
+		// Unified diff numbers are 1-based, except that an empty range names the preceding line.
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// ParseUnifiedDiff parses the hunks of a unified diff, which may cover several files. The line counts
+// in each hunk header delimit its body; a hunk with fewer or more lines than its header announces is
+// an error. File headers are skipped and "\ No newline at end of file" markers are ignored.
+func ParseUnifiedDiff(patch string) ([]Hunk, error) {
+	var hunks []Hunk
+	var current *Hunk
+	oldLeft, newLeft := 0, 0 // lines of the current hunk still expected
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 1
+	for ; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if current != nil && oldLeft == 0 && newLeft == 0 {
+			// The hunk is complete: what follows is another hunk, a file header or trailing text.
+			if line != "" && (line[0] == ' ' || line[0] == '+' && !strings.HasPrefix(line, "+++ ") || line[0] == '-' && !strings.HasPrefix(line, "--- ")) {
+				return nil, fmt.Errorf("fileutil: patch line %d: hunk %s does not match the line counts in its header", lineNo, current.Header())
+			}
+			current = nil
+		}
+		switch {
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case current == nil:
+			if !strings.HasPrefix(line, "@@") {
+				continue // file headers
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("fileutil: patch line %d: %w", lineNo, err)
+			}
+			hunks = append(hunks, h)
+			current = &hunks[len(hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+		case line == "" || line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			kind, text := byte(' '), ""
+			if line != "" {
+				kind, text = line[0], line[1:]
+			}
+			if kind != '+' {
+				oldLeft--
+			}
+			if kind != '-' {
+				newLeft--
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("fileutil: patch line %d: hunk %s does not match the line counts in its header", lineNo, current.Header())
+			}
+			current.Lines = append(current.Lines, DiffLine{kind, text})
+		default:
+			return nil, fmt.Errorf("fileutil: patch line %d: unexpected %q in hunk %s", lineNo, line, current.Header())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil && (oldLeft > 0 || newLeft > 0) {
+		return nil, fmt.Errorf("fileutil: patch line %d: hunk %s does not match the line counts in its header", lineNo, current.Header())
+	}
+	return hunks, nil
+}
+
+func parseHunkHeader(line string) (Hunk, error) {
+	var h Hunk
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return h, fmt.Errorf("malformed hunk header %q", line)
+	}
+	var err error
+	if h.OldStart, h.OldLines, err = parseHunkRange(fields[1][1:]); err != nil {
+		return h, err
+	}
+	if h.NewStart, h.NewLines, err = parseHunkRange(fields[2][1:]); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+func parseHunkRange(s string) (int, int, error) {
+	start, count, found := strings.Cut(s, ",")
+	first, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed hunk range %q", s)
+	}
+	if !found {
+		return first, 1, nil
+	}
+	lines, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed hunk range %q", s)
+	}
+	return first, lines, nil
+}
+
+// ApplyHunks applies hunks, in order, to lines and returns the patched lines. Every context and
+// removed line must match exactly; otherwise the error wraps ErrPatchConflict.
+func ApplyHunks(lines []string, hunks []Hunk) ([]string, error) {
+	result := make([]string, 0, len(lines))
+	pos := 0 // next unconsumed index in lines
+	for n, h := range hunks {
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		if start < pos || start > len(lines) {
+			return nil, fmt.Errorf("%w: hunk %d (%s) is out of range", ErrPatchConflict, n+1, h.Header())
+		}
+		result = append(result, lines[pos:start]...)
+		pos = start
+		for _, line := range h.Lines {
+			if line.Kind == '+' {
+				result = append(result, line.Text)
+				continue
+			}
+			if pos >= len(lines) || lines[pos] != line.Text {
+				return nil, fmt.Errorf("%w: hunk %d (%s) does not match at line %d", ErrPatchConflict, n+1, h.Header(), pos+1)
+			}
+			if line.Kind == ' ' {
+				result = append(result, line.Text)
+			}
+			pos++
+		}
+	}
+	return append(result, lines[pos:]...), nil
+}
+
+// ApplyUnifiedDiff parses patch and applies it to lines.
+func ApplyUnifiedDiff(lines []string, patch string) ([]string, error) {
+	hunks, err := ParseUnifiedDiff(patch)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyHunks(lines, hunks)
+}
+
+// FilesEqual reports whether two files have identical content. It compares sizes first and
+// then streams both files in fixed-size chunks, so memory use does not depend on file size.
+func FilesEqual(file1, file2 string) (bool, error) {
+	f1, err := os.Open(file1)
+	if err != nil {
+		return false, err
+	}
+	defer f1.Close()
+	f2, err := os.Open(file2)
+	if err != nil {
+		return false, err
+	}
+	defer f2.Close()
+
+	info1, err := f1.Stat()
+	if err != nil {
+		return false, err
+	}
+	info2, err := f2.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info1.Mode().IsRegular() && info2.Mode().IsRegular() && info1.Size() != info2.Size() {
+		return false, nil
+	}
+	return ReadersEqual(f1, f2)
+}
+
+// ReadersEqual reports whether two readers yield identical content, reading both in chunks.
+func ReadersEqual(r1, r2 io.Reader) (bool, error) {
+	buf1 := make([]byte, 64*1024)
+	buf2 := make([]byte, 64*1024)
+	for {
+		n1, err1 := io.ReadFull(r1, buf1)
+		n2, err2 := io.ReadFull(r2, buf2)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return false, err1
+		}
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return false, err2
+		}
+		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
+			return false, nil
+		}
+		if err1 != nil || err2 != nil {
+			// At least one reader is exhausted; they are equal only if both are.
+			return (err1 != nil) == (err2 != nil), nil
+		}
+	}
+}
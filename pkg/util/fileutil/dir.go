@@ -12,8 +12,9 @@ func CreateDirectory(path string, perms os.FileMode) error {
 }
 
 // RemoveDirectory deletes a directory and all its contents.
-func RemoveDirectory(path string) error {
-	return os.RemoveAll(path)
+// Pass ToTrash to move the directory into a Trash instead.
+func RemoveDirectory(path string, opts ...DeleteOption) error {
+	return newDeleteConfig(opts).removeAll(path)
 }
 
 // ReadDir reads the directory named by dirname and returns a list of directory entries sorted by filename.
@@ -36,63 +37,17 @@ func EnsureDir(dirName string, perms os.FileMode) error {
 
 // CopyDirectory recursively copies a directory tree, attempting to preserve permissions.
 // Source directory must exist, destination directory must *not* exist.
+// It is CopyDirectoryFS over OSFS(src) and OSFS(dst); use CopyDirectoryFS directly to copy
+// between other FS implementations, e.g. out of an embed.FS.
 func CopyDirectory(src string, dst string) error {
-	src = filepath.Clean(src)
-	dst = filepath.Clean(dst)
-
-	si, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
-	if !si.IsDir() {
-		return ErrSourceNotDirectory(src)
-	}
-
-	_, err = os.Stat(dst)
-	if !os.IsNotExist(err) {
-		return ErrDestinationExists(dst)
-	}
-
-	err = os.MkdirAll(dst, si.Mode())
-	if err != nil {
-		return err
-	}
-
-	entries, err := ioutil.ReadDir(src)
-	if err != nil {
-		return err
-	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			err = CopyDirectory(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			err = CopyFile(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	return CopyDirectoryFS(OSFS(dst), ".", OSFS(src), ".")
 }
 
-// CalculateDirSize returns the total size of files in the specified directory.
+// CalculateDirSize returns the total size of files in the specified directory, walking it in parallel.
+// Entries that cannot be read are left out and reported in the returned error.
+// It is CalculateDirSizeFS over OSFS(dirPath).
 func CalculateDirSize(dirPath string) (int64, error) {
-	var size int64
-	err := filepath.Walk(dirPath, func(_ string, info os.FileInfo, err error) error {
-		if !info.IsDir() {
-			size += info.Size()
-		}
-		return err
-	})
-	return size, err
+	return CalculateDirSizeFS(OSFS(dirPath), ".")
 }
 
 // ErrSourceNotDirectory error returned when source is not a directory.
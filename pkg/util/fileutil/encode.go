@@ -1,13 +1,88 @@
 package fileutil
 
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+)
+
 // EncodeFileToBase64 reads a file and encodes its content to Base64.
+// The result is held in memory; use EncodeBase64File or EncodeBase64 for large files.
 func EncodeFileToBase64(filePath string) (string, error) {
-	// omitted for brevity - implement base64 encoding of file content
-	return "", nil
+	var sb strings.Builder
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := EncodeBase64(&sb, file); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
 }
 
 // DecodeBase64ToFile decodes Base64 content and writes it to a file.
+// The file is only replaced if the whole content decodes successfully.
 func DecodeBase64ToFile(base64Content, filePath string) error {
-	// omitted for brevity - implement base64 decoding to file content
-	return nil
+	return decodeBase64ToFile(strings.NewReader(base64Content), filePath)
+}
+
+// EncodeBase64 streams r to w as standard Base64.
+func EncodeBase64(w io.Writer, r io.Reader) error {
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(enc, r); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// DecodeBase64 streams standard Base64 from r to w. Line breaks in the input are ignored.
+func DecodeBase64(w io.Writer, r io.Reader) error {
+	_, err := io.Copy(w, base64.NewDecoder(base64.StdEncoding, r))
+	return err
+}
+
+// EncodeBase64File encodes the file src as Base64 into dst without loading it into memory.
+func EncodeBase64File(src, dst string) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := CreateAtomic(dst, 0644)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	if err := EncodeBase64(destination, source); err != nil {
+		return err
+	}
+	return destination.Commit()
+}
+
+// DecodeBase64File decodes the Base64 file src into dst without loading it into memory.
+func DecodeBase64File(src, dst string) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	return decodeBase64ToFile(source, dst)
+}
+
+func decodeBase64ToFile(r io.Reader, filePath string) error {
+	destination, err := CreateAtomic(filePath, 0644)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	if err := DecodeBase64(destination, r); err != nil {
+		return err
+	}
+	return destination.Commit()
 }
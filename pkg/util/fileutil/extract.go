@@ -0,0 +1,318 @@
+package fileutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractRule identifies the safety rule an archive entry violated during extraction.
+type ExtractRule string
+
+// Safety rules enforced by the archive extractors.
+const (
+	RulePathTraversal    ExtractRule = "path traversal"
+	RuleAbsolutePath     ExtractRule = "absolute path"
+	RuleSymlinkEscape    ExtractRule = "symlink escape"
+	RuleSymlinkForbidden ExtractRule = "symlink forbidden"
+	RuleTotalSize        ExtractRule = "total size limit"
+	RuleEntryCount       ExtractRule = "entry count limit"
+	RuleCompressionRatio ExtractRule = "compression ratio limit"
+	RuleUnsupportedEntry ExtractRule = "unsupported entry type"
+)
+
+// ExtractError is returned when an archive entry violates one of the extraction rules.
+type ExtractError struct {
+	Rule   ExtractRule
+	Entry  string
+	Detail string
+}
+
+// Error implements the error interface.
+func (e *ExtractError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("fileutil: archive entry %q rejected: %s", e.Entry, e.Rule)
+	}
+	return fmt.Sprintf("fileutil: archive entry %q rejected: %s (%s)", e.Entry, e.Rule, e.Detail)
+}
+
+// ExtractLimits bounds what an archive is allowed to do to the filesystem when extracted.
+// A zero value for any numeric limit disables that limit.
+type ExtractLimits struct {
+	MaxTotalSize        int64   // maximum number of uncompressed bytes written across all entries
+	MaxEntries          int     // maximum number of entries in the archive
+	MaxCompressionRatio float64 // maximum uncompressed/compressed ratio for a single entry
+	AllowSymlinks       bool    // whether symlink entries are created; they must still point inside dest
+}
+
+// DefaultExtractLimits returns conservative limits suitable for untrusted archives.
+func DefaultExtractLimits() ExtractLimits {
+	return ExtractLimits{
+		MaxTotalSize:        1 << 30, // 1 GiB
+		MaxEntries:          10000,
+		MaxCompressionRatio: 100,
+		AllowSymlinks:       false,
+	}
+}
+
+// ratioCheckThreshold is the entry size below which the compression ratio is not checked,
+// since small, highly repetitive files legitimately compress very well.
+const ratioCheckThreshold = 1 << 20
+
+// extractor writes archive entries below dest while enforcing ExtractLimits.
+type extractor struct {
+	root     string // dest as given by the caller
+	dest     string // dest with symlinks resolved
+	limits   ExtractLimits
+	total    int64
+	entries  int
+	source   *countingReader  // compressed input, for stream-level ratio checks
+	progress *progressTracker // optional
+}
+
+func newExtractor(dest string, limits ExtractLimits) (*extractor, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve dest itself so that containment checks compare real paths.
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	return &extractor{root: dest, dest: real, limits: limits}, nil
+}
+
+// display maps an extracted path back below dest as the caller spelled it.
+func (x *extractor) display(target string) string {
+	rel, err := filepath.Rel(x.dest, target)
+	if err != nil {
+		return target
+	}
+	return filepath.Join(x.root, rel)
+}
+
+// addEntry counts an entry against the entry limit.
+func (x *extractor) addEntry(name string) error {
+	x.entries++
+	if x.limits.MaxEntries > 0 && x.entries > x.limits.MaxEntries {
+		return &ExtractError{Rule: RuleEntryCount, Entry: name, Detail: fmt.Sprintf("more than %d entries", x.limits.MaxEntries)}
+	}
+	return nil
+}
+
+// entryDone reports an extracted entry to the progress callback, if any.
+func (x *extractor) entryDone(name string) {
+	if x.progress != nil {
+		x.progress.progress.Bytes = x.total
+		x.progress.done(name)
+	}
+}
+
+// resolve validates an archive entry name and returns the destination path it maps to.
+func (x *extractor) resolve(name string) (string, error) {
+	clean := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(clean, "/") || filepath.VolumeName(clean) != "" || (len(clean) > 1 && clean[1] == ':') {
+		return "", &ExtractError{Rule: RuleAbsolutePath, Entry: name}
+	}
+	clean = path.Clean(clean)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", &ExtractError{Rule: RulePathTraversal, Entry: name}
+	}
+	target := filepath.Join(x.dest, filepath.FromSlash(clean))
+	if !x.within(target) {
+		return "", &ExtractError{Rule: RulePathTraversal, Entry: name}
+	}
+	if err := x.checkParents(name, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// within reports whether p is dest or lies below it.
+func (x *extractor) within(p string) bool {
+	rel, err := filepath.Rel(x.dest, p)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkParents makes sure none of the existing ancestors of target are symlinks leading outside dest,
+// which would otherwise let a later entry write through a previously extracted link.
+func (x *extractor) checkParents(name, target string) error {
+	rel, err := filepath.Rel(x.dest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	current := x.dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil || !x.within(resolved) {
+			return &ExtractError{Rule: RuleSymlinkEscape, Entry: name, Detail: "parent directory is a symlink outside the destination"}
+		}
+	}
+	return nil
+}
+
+// mkdir creates a directory entry.
+func (x *extractor) mkdir(target string, mode os.FileMode) error {
+	return os.MkdirAll(target, mode.Perm()|0700)
+}
+
+// writeFile creates a regular file entry from r. compressed is the size of the entry
+// inside the archive, or 0 when unknown, and is used for the compression ratio check.
+func (x *extractor) writeFile(name, target string, r io.Reader, mode os.FileMode, compressed int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// Never write through an existing symlink.
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := x.copy(name, file, r, compressed); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// copy streams r into w, enforcing the total size and compression ratio limits on the
+// bytes actually produced rather than on the sizes declared in the archive headers.
+func (x *extractor) copy(name string, w io.Writer, r io.Reader, compressed int64) error {
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			written += int64(n)
+			x.total += int64(n)
+			if x.limits.MaxTotalSize > 0 && x.total > x.limits.MaxTotalSize {
+				return &ExtractError{Rule: RuleTotalSize, Entry: name, Detail: fmt.Sprintf("more than %d bytes", x.limits.MaxTotalSize)}
+			}
+			if err := x.checkRatio(name, written, compressed); err != nil {
+				return err
+			}
+			if x.source != nil {
+				if err := x.checkRatio(name, x.total, x.source.n); err != nil {
+					return err
+				}
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// checkRatio enforces the compression ratio limit for a single entry.
+func (x *extractor) checkRatio(name string, uncompressed, compressed int64) error {
+	if x.limits.MaxCompressionRatio <= 0 || compressed <= 0 || uncompressed < ratioCheckThreshold {
+		return nil
+	}
+	if float64(uncompressed)/float64(compressed) > x.limits.MaxCompressionRatio {
+		return &ExtractError{Rule: RuleCompressionRatio, Entry: name, Detail: fmt.Sprintf("ratio exceeds %g", x.limits.MaxCompressionRatio)}
+	}
+	return nil
+}
+
+// symlink creates a symlink entry pointing at linkname, provided symlinks are allowed
+// and the link resolves to a location inside dest.
+func (x *extractor) symlink(name, target, linkname string) error {
+	if !x.limits.AllowSymlinks {
+		return &ExtractError{Rule: RuleSymlinkForbidden, Entry: name}
+	}
+	if linkname == "" || filepath.IsAbs(linkname) || strings.HasPrefix(linkname, `\`) || hasInnerDotDot(linkname) {
+		return &ExtractError{Rule: RuleSymlinkEscape, Entry: name, Detail: "link target " + linkname}
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// Resolve relative to the real parent directory, since it may itself be reached through a link.
+	dir, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if !x.within(filepath.Join(dir, filepath.FromSlash(linkname))) {
+		return &ExtractError{Rule: RuleSymlinkEscape, Entry: name, Detail: "link target " + linkname}
+	}
+	if _, err := os.Lstat(target); err == nil {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(linkname, target)
+}
+
+// hardlink creates a hard link entry to linkname, an earlier entry of the same archive.
+func (x *extractor) hardlink(name, target, linkname string) error {
+	existing, err := x.resolve(linkname)
+	if err != nil {
+		return &ExtractError{Rule: RuleSymlinkEscape, Entry: name, Detail: "link target " + linkname}
+	}
+	info, err := os.Lstat(existing)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &ExtractError{Rule: RuleUnsupportedEntry, Entry: name, Detail: "hard link to non-regular file"}
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(target); err == nil {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	return os.Link(existing, target)
+}
+
+// hasInnerDotDot reports whether a link target climbs back up after descending, e.g. "a/../..",
+// which could escape once "a" is itself a symlink.
+func hasInnerDotDot(linkname string) bool {
+	descended := false
+	for _, part := range strings.Split(strings.ReplaceAll(linkname, `\`, "/"), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if descended {
+				return true
+			}
+		default:
+			descended = true
+		}
+	}
+	return false
+}
@@ -2,7 +2,6 @@ package fileutil
 
 import (
 	"bufio"
-	"bytes"
 	"io"
 	"io/ioutil"
 	"os"
@@ -20,8 +19,9 @@ func ReadFile(filePath string) (string, error) {
 }
 
 // WriteFile writes the data to the file specified by the filePath.
-func WriteFile(filePath string, data string) error {
-	return ioutil.WriteFile(filePath, []byte(data), 0644)
+// Pass Atomic() to replace the file atomically instead of truncating it in place.
+func WriteFile(filePath string, data string, opts ...WriteOption) error {
+	return WriteBinaryFile(filePath, []byte(data), opts...)
 }
 
 // FileExists checks if a file exists and is not a directory before we try using it to prevent further errors.
@@ -33,9 +33,9 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-// DeleteFile deletes the specified file.
-func DeleteFile(filePath string) error {
-	return os.Remove(filePath)
+// DeleteFile deletes the specified file. Pass ToTrash to delete it recoverably.
+func DeleteFile(filePath string, opts ...DeleteOption) error {
+	return newDeleteConfig(opts).remove(filePath)
 }
 
 // CopyFile copies a file from src to dst. If src and dst files exist, and are the same, then return success.
@@ -93,8 +93,17 @@ func ReadBinaryFile(filename string) ([]byte, error) {
 }
 
 // WriteBinaryFile writes data to a file as bytes.
-func WriteBinaryFile(filename string, data []byte) error {
-	return ioutil.WriteFile(filename, data, 0644)
+// Pass Atomic() to replace the file atomically instead of truncating it in place.
+func WriteBinaryFile(filename string, data []byte, opts ...WriteOption) error {
+	file, commit, abort, err := createForWrite(filename, newWriteConfig(opts))
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		abort()
+		return err
+	}
+	return commit()
 }
 
 // ChangeFilePermissions changes the permissions of the specified file.
@@ -102,30 +111,32 @@ func ChangeFilePermissions(filename string, perms os.FileMode) error {
 	return os.Chmod(filename, perms)
 }
 
-// FindFiles walks the directory tree and finds files that match a certain pattern.
+// FindFiles walks the directory tree in parallel and returns the paths matching a certain pattern.
+// As with filepath.Walk, the paths come in lexical walk order and an unreadable entry fails the
+// whole search. It is FindFilesFS over OSFS(rootDir).
 func FindFiles(rootDir string, pattern string) ([]string, error) {
-	var matches []string
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if matched, err := filepath.Match(pattern, filepath.Base(path)); err != nil {
-			return err
-		} else if matched {
-			matches = append(matches, path)
-		}
-		return nil
-	})
+	names, err := FindFilesFS(OSFS(rootDir), ".", pattern)
 	if err != nil {
 		return nil, err
 	}
+	var matches []string
+	if matched, _ := filepath.Match(pattern, filepath.Base(rootDir)); matched {
+		matches = append(matches, rootDir)
+	}
+	for _, name := range names {
+		if name != "." {
+			matches = append(matches, filepath.Join(rootDir, filepath.FromSlash(name)))
+		}
+	}
 	return matches, nil
 }
 
-// BatchRemoveFiles removes multiple files in a single operation.
-func BatchRemoveFiles(files []string) error {
+// BatchRemoveFiles removes multiple files in a single operation, stopping at the first error.
+// Pass ToTrash to move them into a Trash instead.
+func BatchRemoveFiles(files []string, opts ...DeleteOption) error {
+	c := newDeleteConfig(opts)
 	for _, file := range files {
-		if err := os.Remove(file); err != nil {
+		if err := c.remove(file); err != nil {
 			return err
 		}
 	}
@@ -133,16 +144,9 @@ func BatchRemoveFiles(files []string) error {
 }
 
 // CompareFiles checks if two files have the same content.
+// Files are streamed rather than loaded into memory; see FilesEqual.
 func CompareFiles(file1, file2 string) (bool, error) {
-	bytes1, err := ioutil.ReadFile(file1)
-	if err != nil {
-		return false, err
-	}
-	bytes2, err := ioutil.ReadFile(file2)
-	if err != nil {
-		return false, err
-	}
-	return bytes.Equal(bytes1, bytes2), nil
+	return FilesEqual(file1, file2)
 }
 
 // WalkFilesWithFunc applies a given function to all files in a directory tree.
@@ -166,7 +170,7 @@ func GetFileNamesInDir(dirPath string) ([]string, error) {
 }
 
 // DiffFiles compares two files and returns true if they are different.
-// This function does a line-by-line comparison.
+// This function does a line-by-line comparison; use UnifiedDiffFiles to see the differences.
 func DiffFiles(file1Path, file2Path string) (bool, error) {
 	file1, err := os.Open(file1Path)
 	if err != nil {
@@ -183,15 +187,23 @@ func DiffFiles(file1Path, file2Path string) (bool, error) {
 	scanner1 := bufio.NewScanner(file1)
 	scanner2 := bufio.NewScanner(file2)
 
-	for scanner1.Scan() && scanner2.Scan() {
+	for {
+		more1, more2 := scanner1.Scan(), scanner2.Scan()
+		if more1 != more2 {
+			return true, nil // One file had more lines than the other
+		}
+		if !more1 {
+			break
+		}
 		if scanner1.Text() != scanner2.Text() {
 			return true, nil // Files are different
 		}
 	}
-
-	// Check if files have the same length
-	if scanner1.Scan() || scanner2.Scan() {
-		return true, nil // One file had more lines than the other
+	if err := scanner1.Err(); err != nil {
+		return false, err
+	}
+	if err := scanner2.Err(); err != nil {
+		return false, err
 	}
 
 	return false, nil // Files are the same
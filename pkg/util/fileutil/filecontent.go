@@ -7,55 +7,56 @@ import (
 )
 
 // WriteLines writes the lines to the given file.
-func WriteLines(lines []string, path string) error {
-	file, err := os.Create(path)
+// Pass Atomic() to replace the file atomically instead of truncating it in place.
+func WriteLines(lines []string, path string, opts ...WriteOption) error {
+	file, commit, abort, err := createForWrite(path, newWriteConfig(opts))
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	w := bufio.NewWriter(file)
 	for _, line := range lines {
 		_, err := w.WriteString(line + "\n")
 		if err != nil {
+			abort()
 			return err
 		}
 	}
-	return w.Flush() // Make sure all buffered operations are applied to the underlying writer.
+	if err := w.Flush(); err != nil { // Make sure all buffered operations are applied to the underlying writer.
+		abort()
+		return err
+	}
+	return commit()
 }
 
 // ReadLines reads a whole file into memory and returns a slice of its lines.
+// Use ReadLinesFS to read from an FS or an embed.FS, or TailFile to follow a file as it grows.
 func ReadLines(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	return lines, scanner.Err()
+	return ReadLinesFS(OSFS(filepath.Dir(path)), filepath.Base(path))
 }
 
 // AppendToFile appends text to a file, creating the file if it doesn't exist.
+// The write is made under an exclusive lock so that concurrent appenders do not interleave.
+// Long-running writers of logs or exports should use a RotatingWriter instead.
 func AppendToFile(filename string, text string) error {
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	return WithLock(filename, func() error {
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
 
-	if _, err := f.WriteString(text); err != nil {
-		return err
-	}
-	return nil
+		if _, err := f.WriteString(text); err != nil {
+			return err
+		}
+		return f.Close()
+	})
 }
 
 // RemoveContents deletes all the contents of a directory.
-func RemoveContents(dirPath string) error {
+// Pass ToTrash to move each entry into a Trash instead; a trash inside the directory is kept.
+func RemoveContents(dirPath string, opts ...DeleteOption) error {
+	c := newDeleteConfig(opts)
 	d, err := os.Open(dirPath)
 	if err != nil {
 		return err
@@ -68,7 +69,19 @@ func RemoveContents(dirPath string) error {
 	}
 
 	for _, name := range names {
-		err = os.RemoveAll(filepath.Join(dirPath, name))
+		p := filepath.Join(dirPath, name)
+		if c.trash != nil {
+			if rel, inside := c.trash.locate(p); inside {
+				// Keep the trash, emptying the directories leading to it.
+				if rel != "." {
+					if err := RemoveContents(p, opts...); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+		err = c.removeAll(p)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,349 @@
+package fileutil
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// File is an open file of an FS. Files opened for reading only return an error from Write.
+type File interface {
+	fs.File
+	io.Writer
+}
+
+// FS is a writable filesystem. It extends io/fs, so names are slash-separated, unrooted paths
+// as accepted by fs.ValidPath, and every FS can be passed wherever an fs.FS is expected.
+type FS interface {
+	fs.StatFS
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+}
+
+// OSFS returns an FS for the operating system directory tree rooted at dir.
+func OSFS(dir string) FS {
+	return osFS{dir: dir}
+}
+
+type osFS struct {
+	dir string
+}
+
+func (o osFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(o.dir, filepath.FromSlash(name)), nil
+}
+
+func (o osFS) Open(name string) (fs.File, error) {
+	p, err := o.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (o osFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := o.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (o osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := o.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(p)
+}
+
+func (o osFS) ReadFile(name string) ([]byte, error) {
+	p, err := o.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (o osFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	p, err := o.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, flag, perm)
+}
+
+func (o osFS) Mkdir(name string, perm fs.FileMode) error {
+	p, err := o.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (o osFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := o.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+func (o osFS) Remove(name string) error {
+	p, err := o.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (o osFS) RemoveAll(name string) error {
+	p, err := o.join("removeall", name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+func (o osFS) Rename(oldname, newname string) error {
+	oldPath, err := o.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newPath, err := o.join("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// ReadOnly adapts any fs.FS, such as an embed.FS, to the FS interface. Every operation that would
+// modify it fails with fs.ErrPermission.
+func ReadOnly(fsys fs.FS) FS {
+	return readOnlyFS{fsys: fsys}
+}
+
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+func (r readOnlyFS) Open(name string) (fs.File, error) {
+	return r.fsys.Open(name)
+}
+
+func (r readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(r.fsys, name)
+}
+
+func (r readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(r.fsys, name)
+}
+
+func (r readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(r.fsys, name)
+}
+
+func (r readOnlyFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	f, err := r.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyFile{f}, nil
+}
+
+func (r readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+}
+
+func (r readOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+}
+
+func (r readOnlyFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+}
+
+func (r readOnlyFS) RemoveAll(name string) error {
+	return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrPermission}
+}
+
+func (r readOnlyFS) Rename(oldname, newname string) error {
+	return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrPermission}
+}
+
+// readOnlyFile adds a failing Write to an fs.File, keeping ReadDir for directories.
+type readOnlyFile struct {
+	fs.File
+}
+
+func (f readOnlyFile) Write(p []byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+func (f readOnlyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Err: fs.ErrInvalid}
+}
+
+// ReadLinesFS reads a whole file of fsys into memory and returns a slice of its lines.
+func ReadLinesFS(fsys fs.FS, name string) ([]string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readLines(file)
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// WriteFileFS writes data to the named file of fsys, creating it with perm if needed.
+func WriteFileFS(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	file, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// FindFilesFS walks the tree of fsys rooted at root in parallel and returns the paths whose base name
+// matches pattern, in the order fs.WalkDir would visit them. If any entry cannot be read, it returns
+// nil and the error.
+func FindFilesFS(fsys fs.FS, root string, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	var matches []string
+	err := ParallelWalkFS(context.Background(), fsys, root, WalkOptions{}, func(p string, d fs.DirEntry) error {
+		if matched, _ := path.Match(pattern, path.Base(p)); matched {
+			mu.Lock()
+			matches = append(matches, p)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortWalkOrder(matches, '/')
+	return matches, nil
+}
+
+// CalculateDirSizeFS returns the total size of files below dir in fsys, walking the tree in parallel.
+func CalculateDirSizeFS(fsys fs.FS, dir string) (int64, error) {
+	var size atomic.Int64
+	err := ParallelWalkFS(context.Background(), fsys, dir, WalkOptions{}, func(_ string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed since it was listed
+		}
+		size.Add(info.Size())
+		return nil
+	})
+	return size.Load(), err
+}
+
+// CopyDirectoryFS recursively copies the tree at srcDir in src to dstDir in dst, preserving permissions.
+// As with CopyDirectory, the destination directory must not exist. The source may be any fs.FS,
+// for example an embed.FS holding default configuration.
+func CopyDirectoryFS(dst FS, dstDir string, src fs.FS, srcDir string) error {
+	si, err := fs.Stat(src, srcDir)
+	if err != nil {
+		return err
+	}
+	if !si.IsDir() {
+		return ErrSourceNotDirectory(srcDir)
+	}
+	if _, err := dst.Stat(dstDir); !os.IsNotExist(err) {
+		return ErrDestinationExists(dstDir)
+	}
+
+	return fs.WalkDir(src, srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := p[len(srcDir):]
+		if srcDir == "." {
+			rel = "/" + p
+		}
+		target := path.Clean(dstDir + rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return dst.MkdirAll(target, dirPerm(info.Mode()))
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			// Like CopyFile, copy what a symlink points to.
+			if info, err = fs.Stat(src, p); err != nil {
+				return err
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return ErrNonRegularSourceFile(info)
+		}
+		return copyFileFS(dst, target, src, p, info.Mode().Perm())
+	})
+}
+
+// dirPerm returns the permissions of a directory, defaulting to 0755 for filesystems such as
+// embed.FS that report read-only modes.
+func dirPerm(mode fs.FileMode) fs.FileMode {
+	if mode.Perm()&0200 == 0 {
+		return 0755
+	}
+	return mode.Perm()
+}
+
+func copyFileFS(dst FS, dstName string, src fs.FS, srcName string, perm fs.FileMode) error {
+	source, err := src.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := dst.OpenFile(dstName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		return err
+	}
+	return destination.Close()
+}
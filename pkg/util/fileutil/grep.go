@@ -0,0 +1,255 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	// binarySniffLen is how much of a file is checked for NUL bytes to classify it as binary, as git does.
+	binarySniffLen = 8000
+	// grepMaxLine is the longest line Grepper can handle.
+	grepMaxLine = 16 << 20
+)
+
+// ErrIsDirectory is returned when a directory is given to a non-recursive search.
+var ErrIsDirectory = errors.New("fileutil: is a directory")
+
+// GrepOptions configures a Grepper.
+type GrepOptions struct {
+	Regexp     bool // treat the pattern as an RE2 regular expression instead of a literal string
+	IgnoreCase bool
+	Before     int // lines of context to report before each match
+	After      int // lines of context to report after each match
+	MaxCount   int // stop after this many matches per file; 0 means no limit
+
+	// Recursive searches directories given to Files; otherwise they are reported as errors.
+	Recursive bool
+	// Include restricts the files searched to those matching any of these globs, with the syntax of
+	// MatchGlob. Patterns without a slash match the base name. Exclude removes files again.
+	Include []string
+	Exclude []string
+
+	// Binary searches files containing NUL bytes as text. By default a binary file yields a single
+	// match with Binary set, and no line, if it matches at all.
+	Binary bool
+}
+
+// GrepMatch is a matching line.
+type GrepMatch struct {
+	Path       string
+	LineNumber int // 1-based
+	Line       string
+	Before     []string // context before the line, oldest first
+	After      []string // context after the line
+	Binary     bool     // the file is binary; Line and the context are empty
+}
+
+// Grepper searches files for lines matching a pattern.
+type Grepper struct {
+	re   *regexp.Regexp
+	opts GrepOptions
+}
+
+// NewGrepper compiles pattern according to opts.
+func NewGrepper(pattern string, opts GrepOptions) (*Grepper, error) {
+	if !opts.Regexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Grepper{re: re, opts: opts}, nil
+}
+
+// Match reports whether line matches the pattern.
+func (g *Grepper) Match(line string) bool {
+	return g.re.MatchString(line)
+}
+
+// Files searches the given files, and the trees below directories when opts.Recursive is set, yielding
+// matches in file order. Errors for individual files are yielded with a GrepMatch holding just the path;
+// the search carries on with the next file unless the caller stops iterating.
+func (g *Grepper) Files(paths ...string) iter.Seq2[GrepMatch, error] {
+	return func(yield func(GrepMatch, error) bool) {
+		for _, p := range paths {
+			info, err := os.Stat(p)
+			if err != nil {
+				if !yield(GrepMatch{Path: p}, err) {
+					return
+				}
+				continue
+			}
+			if !info.IsDir() {
+				if g.included(p) && !g.searchFile(p, yield) {
+					return
+				}
+				continue
+			}
+			if !g.opts.Recursive {
+				if !yield(GrepMatch{Path: p}, &fs.PathError{Op: "grep", Path: p, Err: ErrIsDirectory}) {
+					return
+				}
+				continue
+			}
+			stopped := false
+			filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if !yield(GrepMatch{Path: path}, err) {
+						stopped = true
+						return filepath.SkipAll
+					}
+					return nil
+				}
+				if !d.Type().IsRegular() || !g.included(path) {
+					return nil
+				}
+				if !g.searchFile(path, yield) {
+					stopped = true
+					return filepath.SkipAll
+				}
+				return nil
+			})
+			if stopped {
+				return
+			}
+		}
+	}
+}
+
+// Reader searches r, reporting matches under name.
+func (g *Grepper) Reader(name string, r io.Reader) iter.Seq2[GrepMatch, error] {
+	return func(yield func(GrepMatch, error) bool) {
+		g.search(name, r, yield)
+	}
+}
+
+func (g *Grepper) included(path string) bool {
+	rel := filepath.ToSlash(path)
+	if len(g.opts.Include) > 0 && !matchAnyGlob(g.opts.Include, rel) {
+		return false
+	}
+	return !matchAnyGlob(g.opts.Exclude, rel)
+}
+
+// searchFile searches one file and reports whether the caller wants more results.
+func (g *Grepper) searchFile(path string, yield func(GrepMatch, error) bool) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return yield(GrepMatch{Path: path}, err)
+	}
+	defer file.Close()
+	return g.search(path, file, yield)
+}
+
+func (g *Grepper) search(name string, r io.Reader, yield func(GrepMatch, error) bool) bool {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if !g.opts.Binary {
+		head, err := br.Peek(binarySniffLen)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return yield(GrepMatch{Path: name}, err)
+		}
+		if bytes.IndexByte(head, 0) >= 0 {
+			return g.searchBinary(name, br, yield)
+		}
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(nil, grepMaxLine)
+	var before []string      // ring of the last opts.Before lines
+	var pending []*GrepMatch // matches still collecting After context
+	count := 0
+	flush := func(all bool) bool {
+		for len(pending) > 0 && (all || len(pending[0].After) == g.opts.After) {
+			if !yield(*pending[0], nil) {
+				return false
+			}
+			pending = pending[1:]
+		}
+		return true
+	}
+
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		for _, m := range pending {
+			if len(m.After) < g.opts.After {
+				m.After = append(m.After, line)
+			}
+		}
+		if !flush(false) {
+			return false
+		}
+		if g.opts.MaxCount > 0 && count >= g.opts.MaxCount {
+			if len(pending) == 0 {
+				return true
+			}
+			continue
+		}
+		if g.re.MatchString(line) {
+			count++
+			m := &GrepMatch{Path: name, LineNumber: n, Line: line}
+			if len(before) > 0 {
+				m.Before = append([]string(nil), before...)
+			}
+			pending = append(pending, m)
+			if !flush(false) {
+				return false
+			}
+		}
+		if g.opts.Before > 0 {
+			if len(before) == g.opts.Before {
+				before = before[1:]
+			}
+			before = append(before, line)
+		}
+	}
+	if !flush(true) {
+		return false
+	}
+	if err := scanner.Err(); err != nil {
+		return yield(GrepMatch{Path: name}, err)
+	}
+	return true
+}
+
+// searchBinary reports a single match for a binary file if any of its lines matches.
+func (g *Grepper) searchBinary(name string, r io.Reader, yield func(GrepMatch, error) bool) bool {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, grepMaxLine)
+	for n := 1; scanner.Scan(); n++ {
+		if g.re.Match(scanner.Bytes()) {
+			return yield(GrepMatch{Path: name, LineNumber: n, Binary: true}, nil)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return yield(GrepMatch{Path: name}, err)
+	}
+	return true
+}
+
+// GrepFiles searches paths with a new Grepper and collects all matches. It stops at the first error.
+func GrepFiles(pattern string, opts GrepOptions, paths ...string) ([]GrepMatch, error) {
+	g, err := NewGrepper(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	var matches []GrepMatch
+	for m, err := range g.Files(paths...) {
+		if err != nil {
+			return matches, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
@@ -0,0 +1,132 @@
+package fileutil
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnorePatterns is a set of .gitignore-style rules.
+// Blank lines and lines starting with # are skipped, a leading ! re-includes a previously ignored path,
+// a trailing / restricts the rule to directories, a leading or inner / anchors the rule to the root,
+// and ** matches any number of directories. The last matching rule wins.
+type IgnorePatterns struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// NewIgnorePatterns parses .gitignore-style rules, one per line.
+func NewIgnorePatterns(lines []string) *IgnorePatterns {
+	p := &IgnorePatterns{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`) // "\#" and "\!" escape a literal first character
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		p.rules = append(p.rules, rule)
+	}
+	return p
+}
+
+// ReadIgnoreFile reads .gitignore-style rules from the file at path.
+func ReadIgnoreFile(path string) (*IgnorePatterns, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewIgnorePatterns(lines), nil
+}
+
+// Match reports whether the slash-separated path rel, relative to the ignore file's directory, is ignored.
+func (p *IgnorePatterns) Match(rel string, isDir bool) bool {
+	if p == nil {
+		return false
+	}
+	ignored := false
+	for _, rule := range p.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if MatchGlob(rule.pattern, rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// MatchGlob reports whether the slash-separated path name matches pattern.
+// Pattern segments use path.Match syntax, and a ** segment matches zero or more path segments.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAnyGlob reports whether rel matches any of the patterns. Patterns without a slash
+// are also matched against the base name, so "*.log" matches logs at any depth.
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+		if !strings.Contains(pattern, "/") && MatchGlob(pattern, path.Base(rel)) {
+			return true
+		}
+	}
+	return false
+}
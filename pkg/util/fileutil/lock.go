@@ -0,0 +1,193 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when a lock is held by someone else and the caller asked not to wait.
+var ErrLocked = errors.New("fileutil: file is locked")
+
+// LockMode selects between shared (reader) and exclusive (writer) advisory locks.
+type LockMode int
+
+// Lock modes.
+const (
+	SharedLock LockMode = iota
+	ExclusiveLock
+)
+
+// lockPollInterval bounds how long a waiting lock sleeps between attempts.
+const lockPollInterval = 50 * time.Millisecond
+
+// FileLock is an advisory lock on a file, shared between processes on the same host.
+// Advisory locks only exclude other code that also takes them. It uses flock on Unix and
+// LockFileEx on Windows; on other platforms locking always succeeds without excluding anyone.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile acquires an advisory lock on path, creating the file if needed, and waits until the lock
+// is granted or ctx is done.
+func LockFile(ctx context.Context, path string, mode LockMode) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := time.Millisecond
+	for {
+		err := tryLock(file, mode)
+		if err == nil {
+			return &FileLock{file: file}, nil
+		}
+		if !errors.Is(err, ErrLocked) {
+			file.Close()
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			file.Close()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if wait *= 2; wait > lockPollInterval {
+			wait = lockPollInterval
+		}
+	}
+}
+
+// LockFileTimeout is like LockFile but gives up after timeout.
+func LockFileTimeout(path string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return LockFile(ctx, path, mode)
+}
+
+// TryLockFile acquires an advisory lock on path without waiting, returning ErrLocked if it is held.
+func TryLockFile(path string, mode LockMode) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := tryLock(file, mode); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// WithLock runs fn while holding an exclusive lock on path, creating the file if needed.
+func WithLock(path string, fn func() error) error {
+	return WithLockContext(context.Background(), path, ExclusiveLock, fn)
+}
+
+// WithLockContext runs fn while holding a lock of the given mode on path, waiting for it until ctx is done.
+func WithLockContext(ctx context.Context, path string, mode LockMode, fn func() error) error {
+	lock, err := LockFile(ctx, path, mode)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return fn()
+}
+
+// PIDLock is a lockfile recording the PID of the process holding it, used to keep a single
+// instance of a program running.
+type PIDLock struct {
+	path string
+	lock *FileLock
+}
+
+// AcquirePIDLock takes the PID lockfile at path for the current process. A lockfile left behind by a
+// process that no longer exists is treated as stale and taken over. If another live process holds it,
+// the error wraps ErrLocked and names that process.
+func AcquirePIDLock(path string) (*PIDLock, error) {
+	lock, err := lockCurrentFile(path)
+	if errors.Is(err, ErrLocked) {
+		if pid, readErr := ReadPIDFile(path); readErr == nil {
+			return nil, fmt.Errorf("%w: %s is held by pid %d", ErrLocked, path, pid)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock was free, but a live process that does not use flock (or a process on another host
+	// sharing the file) may still own the PID recorded in it.
+	if pid, err := ReadPIDFile(path); err == nil && pid != os.Getpid() && processAlive(pid) {
+		lock.Unlock()
+		return nil, fmt.Errorf("%w: %s is held by pid %d", ErrLocked, path, pid)
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return &PIDLock{path: path, lock: lock}, nil
+}
+
+// lockCurrentFile takes an exclusive lock on the file at path without waiting. Release unlinks the
+// lockfile before unlocking it, so the file locked here may have been removed in between, while
+// another process creates and locks a new one; the lock is then retried on the file now at path.
+func lockCurrentFile(path string) (*FileLock, error) {
+	for {
+		lock, err := TryLockFile(path, ExclusiveLock)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := lock.file.Stat()
+		if err != nil {
+			lock.Unlock()
+			return nil, err
+		}
+		current, err := os.Stat(path)
+		if err == nil && os.SameFile(locked, current) {
+			return lock, nil
+		}
+		lock.Unlock()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// Release removes the lockfile and releases the lock.
+func (p *PIDLock) Release() error {
+	err := os.Remove(p.path)
+	if unlockErr := p.lock.Unlock(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// ReadPIDFile returns the PID recorded in a PID lockfile.
+func ReadPIDFile(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("fileutil: %s does not contain a valid pid", path)
+	}
+	return pid, nil
+}
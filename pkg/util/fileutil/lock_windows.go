@@ -0,0 +1,72 @@
+//go:build windows
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+	stillActive             = 259
+)
+
+// Windows byte-range locks are mandatory, so locking the content would make writes through other
+// handles fail, including those of AppendToFile under WithLock. A single byte far beyond any real
+// file size is locked instead, which excludes other lockers just the same.
+const (
+	lockOffsetLow  = 0xFFFFFFFE
+	lockOffsetHigh = 0x7FFFFFFF
+)
+
+func tryLock(file *os.File, mode LockMode) error {
+	flags := uint32(lockfileFailImmediately)
+	if mode == ExclusiveLock {
+		flags |= lockfileExclusiveLock
+	}
+	ol := syscall.Overlapped{Offset: lockOffsetLow, OffsetHigh: lockOffsetHigh}
+	r, _, err := procLockFileEx.Call(file.Fd(), uintptr(flags), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r != 0 {
+		return nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return ErrLocked
+	}
+	return &os.PathError{Op: "LockFileEx", Path: file.Name(), Err: err}
+}
+
+func unlock(file *os.File) error {
+	ol := syscall.Overlapped{Offset: lockOffsetLow, OffsetHigh: lockOffsetHigh}
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return &os.PathError{Op: "UnlockFileEx", Path: file.Name(), Err: err}
+	}
+	return nil
+}
+
+// processAlive reports whether a process with the given PID is running.
+func processAlive(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Access is denied for processes of other users, which therefore exist.
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
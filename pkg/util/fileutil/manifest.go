@@ -0,0 +1,242 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go-infrastructure/pkg/util/cryptoutil"
+	"go-infrastructure/pkg/util/fileutil/cas"
+)
+
+// ManifestVersion is the version written into JSON manifests.
+const ManifestVersion = 1
+
+// ErrManifestUnsigned is returned when verifying the signature of a manifest that has none.
+var ErrManifestUnsigned = errors.New("fileutil: manifest is not signed")
+
+// ManifestEntry records one file of a manifest. Manifests read from sha256sum text carry no size
+// or mode; Size is then -1 and Mode 0, and only the digest is verified.
+type ManifestEntry struct {
+	Path   string      `json:"path"` // slash-separated, relative to the manifest root
+	Size   int64       `json:"size"`
+	Mode   os.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+// Manifest lists the files of a directory tree with their digests, sorted by path.
+type Manifest struct {
+	Version   int             `json:"version"`
+	Files     []ManifestEntry `json:"files"`
+	Signature []byte          `json:"signature,omitempty"`
+}
+
+// BuildManifest hashes every regular file below root, in parallel, into a Manifest.
+func BuildManifest(ctx context.Context, root string, opts WalkOptions) (*Manifest, error) {
+	hashes, err := HashTree(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{Version: ManifestVersion, Files: make([]ManifestEntry, len(hashes))}
+	for i, h := range hashes {
+		m.Files[i] = ManifestEntry{Path: h.Path, Size: h.Size, Mode: h.Mode, SHA256: h.SHA256}
+	}
+	return m, nil
+}
+
+// WriteJSON writes the manifest as indented JSON.
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// WriteSHA256Sums writes the manifest in the text format of sha256sum, so that a release can also be
+// checked with "sha256sum -c". Sizes, modes and the signature are not part of this format.
+func (m *Manifest) WriteSHA256Sums(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range m.Files {
+		name := f.Path
+		if strings.ContainsAny(name, "\\\n\r") {
+			// Same escaping as coreutils: a leading backslash marks an escaped name.
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(name)
+			bw.WriteString("\\")
+		}
+		fmt.Fprintf(bw, "%s  %s\n", f.SHA256, name)
+	}
+	return bw.Flush()
+}
+
+// ReadManifest reads a manifest written by WriteJSON or WriteSHA256Sums, detecting the format.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return &Manifest{Version: ManifestVersion}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\n' || b[0] == '\r' {
+			br.ReadByte()
+			continue
+		}
+		if b[0] == '{' {
+			var m Manifest
+			if err := json.NewDecoder(br).Decode(&m); err != nil {
+				return nil, err
+			}
+			return &m, nil
+		}
+		return readSHA256Sums(br)
+	}
+}
+
+func readSHA256Sums(r io.Reader) (*Manifest, error) {
+	m := &Manifest{Version: ManifestVersion}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		escaped := strings.HasPrefix(text, "\\")
+		if escaped {
+			text = text[1:]
+		}
+		// "<digest> <mode><name>", where mode is a space for text or '*' for binary.
+		digest, name, ok := strings.Cut(text, " ")
+		digest = strings.ToLower(digest)
+		if !ok || len(name) < 2 || (name[0] != ' ' && name[0] != '*') || !cas.ValidDigest(digest) {
+			return nil, fmt.Errorf("fileutil: invalid sha256sum line %d", line)
+		}
+		name = name[1:]
+		if escaped {
+			name = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r").Replace(name)
+		}
+		m.Files = append(m.Files, ManifestEntry{Path: filepath.ToSlash(name), Size: -1, SHA256: digest})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// LoadManifest reads a manifest file in either format.
+func LoadManifest(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadManifest(file)
+}
+
+// Save writes the manifest to path atomically. Paths ending in ".sha256" or named SHA256SUMS are
+// written in sha256sum format, anything else as JSON.
+func (m *Manifest) Save(path string) error {
+	var buf bytes.Buffer
+	var err error
+	if strings.HasSuffix(path, ".sha256") || filepath.Base(path) == "SHA256SUMS" {
+		err = m.WriteSHA256Sums(&buf)
+	} else {
+		err = m.WriteJSON(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// signedPayload returns the bytes covered by the signature: the file list, encoded as compact JSON.
+func (m *Manifest) signedPayload() ([]byte, error) {
+	return json.Marshal(struct {
+		Version int             `json:"version"`
+		Files   []ManifestEntry `json:"files"`
+	}{m.Version, m.Files})
+}
+
+// Sign signs the manifest with cryptoutil.SignData and stores the signature in it.
+// The signature is kept by WriteJSON but not by WriteSHA256Sums.
+func (m *Manifest) Sign(key *rsa.PrivateKey) error {
+	payload, err := m.signedPayload()
+	if err != nil {
+		return err
+	}
+	sig, err := cryptoutil.SignData(key, payload)
+	if err != nil {
+		return err
+	}
+	m.Signature = sig
+	return nil
+}
+
+// VerifySignature checks the signature of the manifest against key.
+func (m *Manifest) VerifySignature(key *rsa.PublicKey) error {
+	if len(m.Signature) == 0 {
+		return ErrManifestUnsigned
+	}
+	payload, err := m.signedPayload()
+	if err != nil {
+		return err
+	}
+	return cryptoutil.VerifySignature(key, payload, m.Signature)
+}
+
+// ManifestReport lists the differences between a manifest and a directory tree.
+type ManifestReport struct {
+	Missing  []string // in the manifest but not on disk
+	Extra    []string // on disk but not in the manifest
+	Modified []string // present in both with a different digest, size or mode
+}
+
+// OK reports whether the tree matched the manifest exactly.
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Modified) == 0
+}
+
+// VerifyManifest hashes the tree at root and compares it with m. Differences are returned in the
+// report; the error is only set when the tree could not be read.
+func VerifyManifest(ctx context.Context, root string, m *Manifest, opts WalkOptions) (*ManifestReport, error) {
+	hashes, err := HashTree(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[string]FileHash, len(hashes))
+	for _, h := range hashes {
+		actual[h.Path] = h
+	}
+
+	report := &ManifestReport{}
+	for _, want := range m.Files {
+		got, ok := actual[want.Path]
+		if !ok {
+			report.Missing = append(report.Missing, want.Path)
+			continue
+		}
+		delete(actual, want.Path)
+		if !strings.EqualFold(got.SHA256, want.SHA256) ||
+			(want.Size >= 0 && got.Size != want.Size) ||
+			(want.Mode != 0 && got.Mode != want.Mode) {
+			report.Modified = append(report.Modified, want.Path)
+		}
+	}
+	for path := range actual {
+		report.Extra = append(report.Extra, path)
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Modified)
+	return report, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package fileutil
+
+// isCrossDevice reports false: without a portable error for cross-device renames, moves between
+// filesystems fail instead of falling back to copying.
+func isCrossDevice(err error) bool {
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package fileutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+const errorNotSameDevice = syscall.Errno(17)
+
+// isCrossDevice reports whether a rename failed because source and target are on different volumes.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
@@ -0,0 +1,151 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SweepReason says why a retention sweep removed a file.
+type SweepReason string
+
+const (
+	SweepExpired SweepReason = "expired" // older than RetentionPolicy.MaxAge
+	SweepQuota   SweepReason = "quota"   // among the oldest files past RetentionPolicy.MaxTotalSize
+)
+
+// RetentionPolicy selects files for removal. Files older than MaxAge are removed first; then, while
+// the rest still add up to more than MaxTotalSize, the oldest of them are removed too.
+type RetentionPolicy struct {
+	MaxAge       time.Duration // zero disables the age limit
+	MaxTotalSize int64         // zero disables the size quota
+	// Include restricts the sweep to files matching any of these globs, with the syntax of MatchGlob,
+	// relative to the swept directory. Patterns without a slash match the base name.
+	Include []string
+	// Trash moves files there instead of deleting them permanently.
+	Trash *Trash
+	// DryRun reports the files that would be removed without touching them.
+	DryRun bool
+}
+
+// SweepItem is a file selected by a retention sweep.
+type SweepItem struct {
+	Path    string // for Trash.Purge, the trash item ID
+	Size    int64
+	ModTime time.Time
+	Reason  SweepReason
+}
+
+// SweepResult reports what a retention sweep removed, or would remove in a dry run.
+type SweepResult struct {
+	Removed   []SweepItem
+	Freed     int64
+	Kept      int
+	KeptBytes int64
+}
+
+type sweepCandidate struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Sweep applies policy to the regular files below dir, using their modification time as their age.
+// Files are removed individually; directories are left in place. Files that cannot be removed are
+// reported in the returned error and counted as kept. A policy.Trash below dir is not swept.
+func Sweep(dir string, policy RetentionPolicy) (*SweepResult, error) {
+	var trashInfo os.FileInfo
+	if policy.Trash != nil {
+		trashInfo, _ = os.Stat(policy.Trash.dir)
+	}
+
+	var mu sync.Mutex
+	var candidates []sweepCandidate
+	walkErr := ParallelWalk(context.Background(), dir, WalkOptions{}, func(path string, d fs.DirEntry) error {
+		if d.IsDir() && trashInfo != nil {
+			if info, err := d.Info(); err == nil && os.SameFile(info, trashInfo) {
+				return filepath.SkipDir
+			}
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(policy.Include) > 0 {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || !matchAnyGlob(policy.Include, filepath.ToSlash(rel)) {
+				return nil
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed since it was listed
+		}
+		mu.Lock()
+		candidates = append(candidates, sweepCandidate{path: path, size: info.Size(), modTime: info.ModTime()})
+		mu.Unlock()
+		return nil
+	})
+	if walkErr != nil && len(candidates) == 0 {
+		return nil, walkErr
+	}
+
+	remove := os.Remove
+	if policy.Trash != nil {
+		remove = func(path string) error {
+			_, err := policy.Trash.Move(path)
+			return err
+		}
+	}
+	result, err := sweep(candidates, policy, remove)
+	return result, errors.Join(walkErr, err)
+}
+
+// sweep selects candidates according to policy and passes them to remove unless it is a dry run.
+func sweep(candidates []sweepCandidate, policy RetentionPolicy, remove func(string) error) (*SweepResult, error) {
+	// Oldest first, so that the quota removes the oldest files.
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].modTime.Equal(candidates[j].modTime) {
+			return candidates[i].modTime.Before(candidates[j].modTime)
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	var total int64
+	for _, c := range candidates {
+		total += c.size
+	}
+	cutoff := time.Now().Add(-policy.MaxAge)
+
+	result := &SweepResult{}
+	var errs []error
+	for _, c := range candidates {
+		var reason SweepReason
+		switch {
+		case policy.MaxAge > 0 && c.modTime.Before(cutoff):
+			reason = SweepExpired
+		case policy.MaxTotalSize > 0 && total > policy.MaxTotalSize:
+			reason = SweepQuota
+		default:
+			result.Kept++
+			result.KeptBytes += c.size
+			continue
+		}
+		if !policy.DryRun {
+			if err := remove(c.path); err != nil {
+				errs = append(errs, err)
+				result.Kept++
+				result.KeptBytes += c.size
+				continue
+			}
+		}
+		total -= c.size
+		result.Removed = append(result.Removed, SweepItem{Path: c.path, Size: c.size, ModTime: c.modTime, Reason: reason})
+		result.Freed += c.size
+	}
+	return result, errors.Join(errs...)
+}
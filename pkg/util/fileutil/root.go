@@ -0,0 +1,237 @@
+package fileutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathEscape is returned when a path given to a Root would resolve outside of it.
+var ErrPathEscape = errors.New("fileutil: path escapes root")
+
+// Root confines file operations to a base directory. Names are slash-separated and relative to the
+// base; names that climb out with "..", absolute names and symlinks leading outside the base are all
+// rejected, including symlinks swapped in concurrently, since resolution happens inside the kernel
+// relative to the open base directory. Use it wherever paths come from users or tenants.
+type Root struct {
+	root *os.Root
+}
+
+// OpenRoot opens dir as a Root.
+func OpenRoot(dir string) (*Root, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Root{root: root}, nil
+}
+
+// Close releases the base directory.
+func (r *Root) Close() error {
+	return r.root.Close()
+}
+
+// Name returns the base directory the Root was opened with.
+func (r *Root) Name() string {
+	return r.root.Name()
+}
+
+// Sub opens a Root for the subdirectory name, e.g. a single tenant's storage.
+func (r *Root) Sub(name string) (*Root, error) {
+	name, err := rootPath("opensub", name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := r.root.OpenRoot(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Root{root: root}, nil
+}
+
+// FS returns a read-only fs.FS view of the Root.
+func (r *Root) FS() fs.FS {
+	return r.root.FS()
+}
+
+// Open opens the named file for reading.
+func (r *Root) Open(name string) (*os.File, error) {
+	name, err := rootPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.Open(name)
+}
+
+// Create creates or truncates the named file.
+func (r *Root) Create(name string) (*os.File, error) {
+	name, err := rootPath("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.Create(name)
+}
+
+// OpenFile opens the named file with the given flags, as os.OpenFile does.
+func (r *Root) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	name, err := rootPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.OpenFile(name, flag, perm)
+}
+
+// ReadFile reads the whole named file.
+func (r *Root) ReadFile(name string) ([]byte, error) {
+	name, err := rootPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.ReadFile(name)
+}
+
+// WriteFile writes data to the named file, creating it with perm if needed.
+func (r *Root) WriteFile(name string, data []byte, perm os.FileMode) error {
+	name, err := rootPath("open", name)
+	if err != nil {
+		return err
+	}
+	return r.root.WriteFile(name, data, perm)
+}
+
+// Stat returns information about the named file, following symlinks inside the Root.
+func (r *Root) Stat(name string) (os.FileInfo, error) {
+	name, err := rootPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.Stat(name)
+}
+
+// Lstat returns information about the named file without following a final symlink.
+func (r *Root) Lstat(name string) (os.FileInfo, error) {
+	name, err := rootPath("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.Lstat(name)
+}
+
+// Mkdir creates the named directory.
+func (r *Root) Mkdir(name string, perm os.FileMode) error {
+	name, err := rootPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return r.root.Mkdir(name, perm)
+}
+
+// MkdirAll creates the named directory along with any missing parents.
+func (r *Root) MkdirAll(name string, perm os.FileMode) error {
+	name, err := rootPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return r.root.MkdirAll(name, perm)
+}
+
+// Remove removes the named file or empty directory.
+func (r *Root) Remove(name string) error {
+	name, err := rootPath("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrInvalid}
+	}
+	return r.root.Remove(name)
+}
+
+// RemoveAll removes the named path and everything below it. The base directory itself cannot be removed.
+func (r *Root) RemoveAll(name string) error {
+	name, err := rootPath("removeall", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &os.PathError{Op: "removeall", Path: name, Err: os.ErrInvalid}
+	}
+	return r.root.RemoveAll(name)
+}
+
+// Rename renames oldname to newname, both inside the Root.
+func (r *Root) Rename(oldname, newname string) error {
+	oldname, err := rootPath("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newname, err = rootPath("rename", newname)
+	if err != nil {
+		return err
+	}
+	return r.root.Rename(oldname, newname)
+}
+
+// Symlink creates name as a symlink to target. The target must be relative and resolve inside the Root,
+// so that the link stays safe for code that follows it without going through the Root.
+func (r *Root) Symlink(target, name string) error {
+	name, err := rootPath("symlink", name)
+	if err != nil {
+		return err
+	}
+	if path.IsAbs(target) || filepath.IsAbs(target) || hasInnerDotDot(target) || escapesRoot(path.Join(path.Dir(name), filepath.ToSlash(target))) {
+		return &os.LinkError{Op: "symlink", Old: target, New: name, Err: ErrPathEscape}
+	}
+	return r.root.Symlink(target, name)
+}
+
+// Readlink returns the target of the named symlink.
+func (r *Root) Readlink(name string) (string, error) {
+	name, err := rootPath("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return r.root.Readlink(name)
+}
+
+// Chmod changes the mode of the named file.
+func (r *Root) Chmod(name string, mode os.FileMode) error {
+	name, err := rootPath("chmod", name)
+	if err != nil {
+		return err
+	}
+	return r.root.Chmod(name, mode)
+}
+
+// Walk walks the tree rooted at name, as fs.WalkDir does, without leaving the Root.
+// Symlinks are reported but not followed.
+func (r *Root) Walk(name string, fn fs.WalkDirFunc) error {
+	name, err := rootPath("walk", name)
+	if err != nil {
+		return err
+	}
+	return fs.WalkDir(r.root.FS(), name, fn)
+}
+
+// rootPath validates a name passed to a Root method and returns it cleaned.
+func rootPath(op, name string) (string, error) {
+	if name == "" {
+		return "", &os.PathError{Op: op, Path: name, Err: os.ErrInvalid}
+	}
+	if path.IsAbs(name) || filepath.IsAbs(name) {
+		return "", &os.PathError{Op: op, Path: name, Err: ErrPathEscape}
+	}
+	clean := path.Clean(filepath.ToSlash(name))
+	if escapesRoot(clean) {
+		return "", &os.PathError{Op: op, Path: name, Err: ErrPathEscape}
+	}
+	return clean, nil
+}
+
+// escapesRoot reports whether a cleaned relative slash path climbs above its starting directory.
+func escapesRoot(clean string) bool {
+	return clean == ".." || strings.HasPrefix(clean, "../")
+}
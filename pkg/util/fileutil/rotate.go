@@ -0,0 +1,279 @@
+package fileutil
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeFormat is the timestamp inserted into backup names, in UTC.
+const rotateTimeFormat = "20060102T150405.000"
+
+// RotateOptions configures a RotatingWriter. With neither MaxSize nor MaxAge set, the file only
+// rotates when Rotate is called.
+type RotateOptions struct {
+	// MaxSize rotates the file before a write would take it past this many bytes.
+	MaxSize int64
+	// MaxAge rotates the file once it has been written to for this long, measured from when the
+	// writer opened or created it.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files to keep; older ones are deleted. Zero keeps them all.
+	MaxBackups int
+	// Compress gzips rotated files in the background.
+	Compress bool
+	// Perm is used when creating files. It defaults to 0644.
+	Perm os.FileMode
+}
+
+// RotatingWriter is an io.WriteCloser that appends to a file and rolls it over by size, age or both.
+// A rotated file is renamed next to the original with a timestamp, e.g. app.log becomes
+// app-20240102T150405.000.log, and optionally compressed to app-20240102T150405.000.log.gz.
+// It is safe for concurrent use; each Write lands in a single file.
+type RotatingWriter struct {
+	path string
+	opts RotateOptions
+	now  func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	// Background compression and pruning run one at a time.
+	bg    sync.Mutex
+	wg    sync.WaitGroup
+	bgErr error
+}
+
+// NewRotatingWriter opens path for appending, creating it and its directory if needed.
+func NewRotatingWriter(path string, opts RotateOptions) (*RotatingWriter, error) {
+	if opts.Perm == 0 {
+		opts.Perm = 0644
+	}
+	w := &RotatingWriter{path: path, opts: opts, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, w.opts.Perm)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.size, w.opened = file, info.Size(), w.now()
+	return nil
+}
+
+// Write appends p to the current file, rotating first if the size or age limit would be exceeded.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.due(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n more bytes.
+func (w *RotatingWriter) due(n int64) bool {
+	if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.MaxAge > 0 && w.now().Sub(w.opened) >= w.opts.MaxAge
+}
+
+// Rotate rolls the file over immediately, e.g. on SIGHUP.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+func (w *RotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	backup := w.backupName(w.now())
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.bg.Lock()
+		defer w.bg.Unlock()
+
+		err := w.compress(backup)
+		if pruneErr := w.prune(); err == nil {
+			err = pruneErr
+		}
+		if err != nil {
+			w.bgErr = errors.Join(w.bgErr, err)
+		}
+	}()
+	return nil
+}
+
+// backupName returns an unused name for a backup taken at t.
+func (w *RotatingWriter) backupName(t time.Time) string {
+	prefix, ext := w.backupParts()
+	stamp := t.UTC().Format(rotateTimeFormat)
+	name := prefix + stamp + ext
+	for i := 1; ; i++ {
+		_, err := os.Lstat(name)
+		_, gzErr := os.Lstat(name + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return name
+		}
+		// Several rotations within a millisecond: extend the timestamp so names still sort in order.
+		name = prefix + stamp + "." + strings.Repeat("z", i) + ext
+	}
+}
+
+// backupParts splits the path into the part before and after the timestamp of backup names.
+func (w *RotatingWriter) backupParts() (prefix, ext string) {
+	ext = filepath.Ext(w.path)
+	return strings.TrimSuffix(w.path, ext) + "-", ext
+}
+
+func (w *RotatingWriter) compress(backup string) error {
+	if !w.opts.Compress {
+		return nil
+	}
+	src, err := os.Open(backup)
+	if os.IsNotExist(err) {
+		return nil // already pruned by a later rotation
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := CreateAtomic(backup+".gz", w.opts.Perm)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := dst.Commit(); err != nil {
+		return err
+	}
+	return os.Remove(backup)
+}
+
+// Backups returns the rotated files, oldest first.
+func (w *RotatingWriter) Backups() ([]string, error) {
+	prefix, ext := w.backupParts()
+	dir, base := filepath.Split(prefix)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), base)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		if !ok || len(stamp) < len(rotateTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(rotateTimeFormat, stamp[:len(rotateTimeFormat)]); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(backups) // timestamps sort chronologically
+	return backups, nil
+}
+
+// prune removes the oldest backups beyond MaxBackups.
+func (w *RotatingWriter) prune() error {
+	if w.opts.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(backups) > w.opts.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		backups = backups[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// Sync commits the current file to stable storage.
+func (w *RotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.file.Sync()
+}
+
+// Close closes the file and waits for background compression to finish, returning any error it hit.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return os.ErrClosed
+	}
+	w.closed = true
+	err := w.file.Close()
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	w.bg.Lock()
+	defer w.bg.Unlock()
+	return errors.Join(err, w.bgErr)
+}
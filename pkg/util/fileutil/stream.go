@@ -0,0 +1,349 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ArchiveEntry describes a single entry of an archive, either to be written by WriteArchive
+// or as delivered by ReadZipEntries and ReadTarEntries.
+type ArchiveEntry struct {
+	Name     string // slash-separated path inside the archive
+	Mode     os.FileMode
+	Size     int64 // content size of regular files; required by the tar formats
+	ModTime  time.Time
+	Linkname string    // symlink target, for symlink entries
+	Body     io.Reader // content of regular files; may be nil for empty files
+}
+
+// EntryIterator yields archive entries one at a time. Next returns io.EOF when there are no more entries.
+type EntryIterator interface {
+	Next() (*ArchiveEntry, error)
+}
+
+// EntryIteratorFunc adapts an ordinary function to the EntryIterator interface.
+type EntryIteratorFunc func() (*ArchiveEntry, error)
+
+// Next calls f.
+func (f EntryIteratorFunc) Next() (*ArchiveEntry, error) {
+	return f()
+}
+
+// EntryFunc is called for every entry read from an archive. For regular files, entry.Body
+// yields the content and is only valid until the function returns.
+type EntryFunc func(entry *ArchiveEntry) error
+
+// ArchiveProgress reports how far a streaming archive operation has got.
+type ArchiveProgress struct {
+	Entry   string // name of the entry just processed
+	Entries int    // number of entries processed so far
+	Bytes   int64  // number of uncompressed content bytes processed so far
+}
+
+// StreamOptions configures the streaming archive functions.
+type StreamOptions struct {
+	// Progress, if set, is called after each entry has been processed.
+	Progress func(ArchiveProgress)
+}
+
+// progressTracker accumulates ArchiveProgress and forwards it to the caller.
+type progressTracker struct {
+	fn       func(ArchiveProgress)
+	progress ArchiveProgress
+}
+
+// wrap returns a reader that counts the content bytes of the current entry.
+func (p *progressTracker) wrap(r io.Reader) io.Reader {
+	if r == nil || p.fn == nil {
+		return r
+	}
+	return &progressReader{r: r, p: p}
+}
+
+func (p *progressTracker) done(name string) {
+	if p.fn == nil {
+		return
+	}
+	p.progress.Entry = name
+	p.progress.Entries++
+	p.fn(p.progress)
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progressTracker
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.progress.Bytes += int64(n)
+	return n, err
+}
+
+// WriteArchive writes the entries produced by next to w as an archive in the given format
+// (FormatZip, FormatTar or FormatTarGz), without touching the filesystem. Entries without
+// permission bits get 0644, or 0755 for directories. The entries themselves are not modified.
+func WriteArchive(w io.Writer, format ArchiveFormat, next EntryIterator, opts StreamOptions) error {
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+	tracker := &progressTracker{fn: opts.Progress}
+	for {
+		entry, err := next.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Name == "" {
+			return fmt.Errorf("fileutil: archive entry without a name")
+		}
+		copied := *entry // fill in the defaults without modifying the caller's entry
+		entry = &copied
+		if entry.ModTime.IsZero() {
+			entry.ModTime = time.Now()
+		}
+		if entry.Mode.Perm() == 0 {
+			switch {
+			case entry.Mode.IsDir():
+				entry.Mode |= 0755
+			case entry.Mode&os.ModeSymlink != 0:
+				entry.Mode |= 0777
+			default:
+				entry.Mode |= 0644
+			}
+		}
+		entry.Body = tracker.wrap(entry.Body)
+		if err := aw.add(entry); err != nil {
+			return err
+		}
+		tracker.done(entry.Name)
+	}
+	return aw.close()
+}
+
+// ReadZipEntries calls fn for each entry of the zip archive read from r, which is size bytes long.
+func ReadZipEntries(r io.ReaderAt, size int64, fn EntryFunc, opts StreamOptions) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	tracker := &progressTracker{fn: opts.Progress}
+	for _, f := range zr.File {
+		if err := readZipEntry(f, fn, tracker); err != nil {
+			return err
+		}
+		tracker.done(f.Name)
+	}
+	return nil
+}
+
+func readZipEntry(f *zip.File, fn EntryFunc, tracker *progressTracker) error {
+	entry := &ArchiveEntry{Name: f.Name, Mode: f.Mode(), ModTime: f.Modified}
+	if entry.Mode.IsDir() {
+		return fn(entry)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if entry.Mode&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		entry.Linkname = string(target)
+		return fn(entry)
+	}
+	entry.Size = int64(f.UncompressedSize64)
+	entry.Body = tracker.wrap(rc)
+	return fn(entry)
+}
+
+// ReadTarEntries calls fn for each entry of the tar or tar.gz stream read from r,
+// detecting gzip compression automatically.
+func ReadTarEntries(r io.Reader, fn EntryFunc, opts StreamOptions) error {
+	br := bufio.NewReader(r)
+	var tr *tar.Reader
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		tr = tar.NewReader(gzipReader)
+	} else {
+		tr = tar.NewReader(br)
+	}
+
+	tracker := &progressTracker{fn: opts.Progress}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry := &ArchiveEntry{
+			Name:     header.Name,
+			Mode:     header.FileInfo().Mode(),
+			ModTime:  header.ModTime,
+			Linkname: header.Linkname,
+		}
+		if header.Typeflag == tar.TypeReg {
+			entry.Size = header.Size
+			entry.Body = tracker.wrap(tr)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+		tracker.done(header.Name)
+	}
+}
+
+// UnzipReader extracts the zip archive read from r, which is size bytes long, into dest
+// with the same safety rules as UnzipWithLimits. It suits uploads held in memory or
+// other non-file sources. opts.Progress is called after each entry has been extracted.
+func UnzipReader(r io.ReaderAt, size int64, dest string, limits ExtractLimits, opts StreamOptions) ([]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	x, err := newExtractor(dest, limits)
+	if err != nil {
+		return nil, err
+	}
+	x.progress = &progressTracker{fn: opts.Progress}
+	return unzip(x, zr)
+}
+
+// UntarReader extracts the tar or tar.gz stream read from r into dest with the same safety rules
+// as UntarWithLimits. Unlike zip, tar can be extracted straight from a request body.
+// opts.Progress is called after each entry has been extracted.
+func UntarReader(r io.Reader, dest string, limits ExtractLimits, opts StreamOptions) ([]string, error) {
+	x, err := newExtractor(dest, limits)
+	if err != nil {
+		return nil, err
+	}
+	x.progress = &progressTracker{fn: opts.Progress}
+	return untar(x, r)
+}
+
+func newArchiveEntry(name string, info os.FileInfo, link string, modTime time.Time) *ArchiveEntry {
+	if modTime.IsZero() {
+		modTime = info.ModTime()
+	}
+	entry := &ArchiveEntry{Name: name, Mode: info.Mode(), ModTime: modTime, Linkname: link}
+	if info.Mode().IsRegular() {
+		entry.Size = info.Size()
+	}
+	return entry
+}
+
+// archiveWriter writes entries in one archive format.
+type archiveWriter interface {
+	add(entry *ArchiveEntry) error
+	close() error
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) (archiveWriter, error) {
+	switch format {
+	case FormatUnknown, FormatZip:
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}, nil
+	case FormatTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(w)}, nil
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz}, nil
+	}
+	return nil, fmt.Errorf("fileutil: cannot write %s archives", format)
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipArchiveWriter) add(entry *ArchiveEntry) error {
+	header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: entry.ModTime.UTC()}
+	header.SetMode(entry.Mode)
+	if entry.Mode.IsDir() {
+		header.Name = strings.TrimSuffix(header.Name, "/") + "/"
+		header.Method = zip.Store
+	}
+	if entry.Mode&os.ModeSymlink != 0 {
+		header.Method = zip.Store
+	}
+
+	writer, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	switch {
+	case entry.Mode&os.ModeSymlink != 0:
+		_, err = io.WriteString(writer, entry.Linkname)
+	case entry.Body != nil:
+		_, err = io.Copy(writer, entry.Body)
+	}
+	return err
+}
+
+func (z *zipArchiveWriter) close() error {
+	return z.zw.Close()
+}
+
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (t *tarArchiveWriter) add(entry *ArchiveEntry) error {
+	header := &tar.Header{
+		Name:    entry.Name,
+		Mode:    int64(entry.Mode.Perm()),
+		ModTime: entry.ModTime.Truncate(time.Second),
+		Format:  tar.FormatPAX,
+	}
+	switch {
+	case entry.Mode.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Name = strings.TrimSuffix(header.Name, "/") + "/"
+	case entry.Mode&os.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = entry.Linkname
+	default:
+		header.Typeflag = tar.TypeReg
+		header.Size = entry.Size
+	}
+
+	if err := t.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeReg || entry.Body == nil {
+		return nil
+	}
+	_, err := io.Copy(t.tw, entry.Body)
+	return err
+}
+
+func (t *tarArchiveWriter) close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SyncAction is what SyncDir does, or would do, to a destination path.
+type SyncAction string
+
+// Sync actions.
+const (
+	SyncCreate SyncAction = "create"
+	SyncUpdate SyncAction = "update"
+	SyncDelete SyncAction = "delete"
+	SyncSkip   SyncAction = "skip"
+)
+
+// SyncCompare selects how SyncDir decides whether a file changed.
+type SyncCompare int
+
+// Comparison modes.
+const (
+	// CompareSizeModTime treats files with equal size and modification time as unchanged. It is fast
+	// and relies on SyncDir preserving modification times when it copies.
+	CompareSizeModTime SyncCompare = iota
+	// CompareContent compares SHA-256 digests of files whose sizes match.
+	CompareContent
+)
+
+// SyncOptions configures SyncDir.
+type SyncOptions struct {
+	DryRun  bool        // only compute the plan
+	Delete  bool        // delete destination entries that do not exist in the source
+	Exclude []string    // patterns, relative to the roots, that are neither copied nor deleted; see MatchGlob
+	Compare SyncCompare // how to detect changed files
+	Workers int         // number of files copied in parallel; defaults to 4
+}
+
+// SyncOp is one step of a SyncPlan.
+type SyncOp struct {
+	Action SyncAction
+	Path   string // slash-separated, relative to the roots
+	IsDir  bool
+	Size   int64 // size of the source file for create and update
+}
+
+// SyncPlan lists what SyncDir did or, in dry-run mode, would do.
+type SyncPlan struct {
+	Ops []SyncOp
+}
+
+// Count returns the number of operations with the given action.
+func (p *SyncPlan) Count(action SyncAction) int {
+	n := 0
+	for _, op := range p.Ops {
+		if op.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders the plan as a diff-like report, one changed path per line prefixed with
+// "+" for created, "~" for updated and "-" for deleted entries. Skipped entries are left out.
+func (p *SyncPlan) String() string {
+	var b strings.Builder
+	for _, op := range p.Ops {
+		var sign string
+		switch op.Action {
+		case SyncCreate:
+			sign = "+"
+		case SyncUpdate:
+			sign = "~"
+		case SyncDelete:
+			sign = "-"
+		default:
+			continue
+		}
+		name := op.Path
+		if op.IsDir {
+			name += "/"
+		}
+		fmt.Fprintf(&b, "%s %s\n", sign, name)
+	}
+	return b.String()
+}
+
+// SyncDir makes dst mirror src, like rsync. Unchanged files are skipped, new and changed files are
+// copied with their permissions and modification times, and with opts.Delete extraneous entries
+// in dst are removed. Unlike CopyDirectory, dst may already exist. The returned plan lists every
+// entry considered; with opts.DryRun nothing is changed.
+func SyncDir(src, dst string, opts SyncOptions) (*SyncPlan, error) {
+	src = filepath.Clean(src)
+	dst = filepath.Clean(dst)
+	si, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if !si.IsDir() {
+		return nil, ErrSourceNotDirectory(src)
+	}
+
+	plan, err := planSync(src, dst, opts)
+	if err != nil || opts.DryRun {
+		return plan, err
+	}
+	return plan, applySync(src, dst, si.Mode().Perm(), plan, opts)
+}
+
+func planSync(src, dst string, opts SyncOptions) (*SyncPlan, error) {
+	plan := &SyncPlan{}
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == src {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchAnyGlob(opts.Exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		seen[rel] = true
+
+		op := SyncOp{Path: rel, IsDir: info.IsDir()}
+		if info.Mode().IsRegular() {
+			op.Size = info.Size()
+		}
+		op.Action, err = syncAction(p, filepath.Join(dst, filepath.FromSlash(rel)), info, opts.Compare)
+		if err != nil {
+			return err
+		}
+		plan.Ops = append(plan.Ops, op)
+		return nil
+	})
+	if err != nil {
+		return plan, err
+	}
+
+	if !opts.Delete {
+		return plan, nil
+	}
+	err = filepath.Walk(dst, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p == dst {
+			return nil
+		}
+		rel, err := filepath.Rel(dst, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchAnyGlob(opts.Exclude, rel) || seen[rel] {
+			if info.IsDir() && !seen[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		plan.Ops = append(plan.Ops, SyncOp{Action: SyncDelete, Path: rel, IsDir: info.IsDir()})
+		if info.IsDir() {
+			return filepath.SkipDir // removed as a whole
+		}
+		return nil
+	})
+	return plan, err
+}
+
+// syncAction decides what to do with the destination of a source entry.
+func syncAction(srcPath, dstPath string, si os.FileInfo, compare SyncCompare) (SyncAction, error) {
+	di, err := os.Lstat(dstPath)
+	if os.IsNotExist(err) {
+		return SyncCreate, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if si.Mode().Type() != di.Mode().Type() {
+		return SyncUpdate, nil
+	}
+
+	switch {
+	case si.IsDir():
+		if si.Mode().Perm() != di.Mode().Perm() {
+			return SyncUpdate, nil
+		}
+		return SyncSkip, nil
+	case si.Mode()&os.ModeSymlink != 0:
+		srcLink, err := os.Readlink(srcPath)
+		if err != nil {
+			return "", err
+		}
+		dstLink, err := os.Readlink(dstPath)
+		if err != nil || srcLink != dstLink {
+			return SyncUpdate, nil
+		}
+		return SyncSkip, nil
+	}
+
+	if si.Size() != di.Size() || si.Mode().Perm() != di.Mode().Perm() {
+		return SyncUpdate, nil
+	}
+	if compare == CompareContent {
+		srcSum, err := CalculateFileSHA256(srcPath)
+		if err != nil {
+			return "", err
+		}
+		dstSum, err := CalculateFileSHA256(dstPath)
+		if err != nil {
+			return "", err
+		}
+		if srcSum != dstSum {
+			return SyncUpdate, nil
+		}
+		return SyncSkip, nil
+	}
+	if !si.ModTime().Equal(di.ModTime()) {
+		return SyncUpdate, nil
+	}
+	return SyncSkip, nil
+}
+
+func applySync(src, dst string, rootPerm os.FileMode, plan *SyncPlan, opts SyncOptions) (err error) {
+	// Directories stay writable while their contents change and get their final modes bottom-up at
+	// the end, so that read-only source directories can still be filled.
+	modes := &dirModes{dst: dst, final: make(map[string]os.FileMode)}
+	defer func() {
+		err = errors.Join(err, modes.restore())
+	}()
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		if err := os.MkdirAll(dst, rootPerm|0700); err != nil {
+			return err
+		}
+		modes.final["."] = rootPerm
+	}
+
+	// Directories first, in walk order, so that parents exist before their files are copied.
+	var files []SyncOp
+	for _, op := range plan.Ops {
+		if op.Action == SyncSkip {
+			continue
+		}
+		if err := modes.open(path.Dir(op.Path)); err != nil {
+			return err
+		}
+		if !op.IsDir {
+			if op.Action != SyncDelete {
+				files = append(files, op)
+			}
+			continue
+		}
+		if op.Action == SyncDelete {
+			continue
+		}
+		perm, err := syncDirEntry(filepath.Join(src, filepath.FromSlash(op.Path)), filepath.Join(dst, filepath.FromSlash(op.Path)))
+		if err != nil {
+			return err
+		}
+		if err := modes.open(op.Path); err != nil {
+			return err
+		}
+		modes.final[op.Path] = perm
+	}
+
+	if err := syncFiles(src, dst, files, opts.Workers); err != nil {
+		return err
+	}
+
+	// Deletions last, deepest first.
+	var deletes []string
+	for _, op := range plan.Ops {
+		if op.Action == SyncDelete {
+			deletes = append(deletes, op.Path)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(deletes)))
+	for _, rel := range deletes {
+		if err := removeAllForce(filepath.Join(dst, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dirModes tracks destination directories made writable during a sync and the modes they end up with.
+type dirModes struct {
+	dst   string
+	final map[string]os.FileMode // slash-separated path relative to dst -> permissions to apply at the end
+}
+
+// open makes the directory rel writable by its owner, remembering its current permissions as the
+// final ones unless others were set already.
+func (m *dirModes) open(rel string) error {
+	if _, ok := m.final[rel]; ok {
+		return nil
+	}
+	p := filepath.Join(m.dst, filepath.FromSlash(rel))
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	m.final[rel] = info.Mode().Perm()
+	if info.Mode().Perm()&0700 == 0700 {
+		return nil
+	}
+	return os.Chmod(p, info.Mode().Perm()|0700)
+}
+
+// restore applies the final permissions, children before their parents.
+func (m *dirModes) restore() error {
+	rels := make([]string, 0, len(m.final))
+	for rel := range m.final {
+		rels = append(rels, rel)
+	}
+	sortWalkOrder(rels, '/')
+	var errs []error
+	for i := len(rels) - 1; i >= 0; i-- {
+		p := filepath.Join(m.dst, filepath.FromSlash(rels[i]))
+		info, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			continue // deleted by the sync
+		}
+		if err == nil && info.Mode().Perm() != m.final[rels[i]] {
+			err = os.Chmod(p, m.final[rels[i]])
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeAllForce is os.RemoveAll for trees that may contain read-only directories.
+func removeAllForce(p string) error {
+	filepath.WalkDir(p, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(p, 0700) // the walk lists the directory after this call
+		}
+		return nil
+	})
+	return os.RemoveAll(p)
+}
+
+// syncDirEntry creates or fixes up a destination directory, leaving it writable for the files still
+// to be copied into it, and returns the permissions it should end up with.
+func syncDirEntry(srcPath, dstPath string) (os.FileMode, error) {
+	si, err := os.Stat(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	if di, err := os.Lstat(dstPath); err == nil && !di.IsDir() {
+		if err := os.Remove(dstPath); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.MkdirAll(dstPath, si.Mode().Perm()|0700); err != nil {
+		return 0, err
+	}
+	return si.Mode().Perm(), nil
+}
+
+// syncFiles copies files with a bounded number of workers and returns every error encountered.
+func syncFiles(src, dst string, files []SyncOp, workers int) error {
+	if workers <= 0 {
+		workers = 4
+	}
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var errs []error
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				native := filepath.FromSlash(rel)
+				if err := syncFile(filepath.Join(src, native), filepath.Join(dst, native)); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %w", rel, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, op := range files {
+		jobs <- op.Path
+	}
+	close(jobs)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// syncFile replaces dstPath with a copy of srcPath, preserving permissions and modification time.
+// Regular files are written atomically so readers never see a partial copy.
+func syncFile(srcPath, dstPath string) error {
+	si, err := os.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+	if di, err := os.Lstat(dstPath); err == nil && (di.IsDir() || si.Mode()&os.ModeSymlink != 0) {
+		if err := removeAllForce(dstPath); err != nil {
+			return err
+		}
+	}
+
+	if si.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(srcPath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dstPath)
+	}
+	if !si.Mode().IsRegular() {
+		return ErrNonRegularSourceFile(si)
+	}
+
+	source, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := CreateAtomic(dstPath, si.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+	// The source permissions win over the umask and over those of the file being replaced.
+	destination.perm, destination.chmod = si.Mode().Perm(), true
+
+	if _, err := io.Copy(destination, source); err != nil {
+		return err
+	}
+	if err := destination.Commit(); err != nil {
+		return err
+	}
+	return os.Chtimes(dstPath, si.ModTime(), si.ModTime())
+}
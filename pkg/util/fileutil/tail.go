@@ -0,0 +1,369 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tailFingerprintLen is how much of the start of a file, and of the data just before the read
+// position, is hashed to recognise it when resuming.
+const tailFingerprintLen = 1024
+
+// TailOptions configures TailFile. The starting position is taken from the first of OffsetFile,
+// Offset, FromStart and Lines that applies.
+type TailOptions struct {
+	// Lines starts with the last Lines lines of the file. Zero starts at the end, like "tail -n 0 -F".
+	Lines int
+	// FromStart starts at the beginning of the file.
+	FromStart bool
+	// Offset starts at this byte offset when positive.
+	Offset int64
+	// OffsetFile persists the position whenever it changes, and resumes from it on the next start if
+	// the file is still the same one: same inode, at least as long, and with the same data around the
+	// start and before the saved position. Otherwise the current file is read from the start.
+	OffsetFile string
+	// PollInterval is how often the file is checked for new data, truncation and rotation.
+	// It defaults to 250ms.
+	PollInterval time.Duration
+	// Buffer is the capacity of the Lines channel. It defaults to 64.
+	Buffer int
+}
+
+// TailLine is a line read by a Tail, without its line ending.
+type TailLine struct {
+	Text   string
+	Offset int64 // offset just after the line in the file it was read from
+}
+
+// Tail follows a growing file, like "tail -F". It keeps reading across truncation and across
+// logrotate-style rotation, where the file is renamed or removed and then recreated: the rest of the
+// old file is read before switching to the new one. A final line without a newline is only delivered
+// once the file is rotated.
+type Tail struct {
+	// Lines delivers the lines in order. It is closed when the context is cancelled or on a fatal error.
+	Lines <-chan TailLine
+
+	path string
+	opts TailOptions
+	file *os.File // file being read, owned by the tail goroutine
+	out  chan TailLine
+	done chan struct{}
+	err  error
+
+	mu     sync.Mutex
+	offset int64
+	saved  *tailOffset // last state written to OffsetFile, owned by the tail goroutine
+}
+
+// TailFile starts following path until ctx is cancelled. The file must exist when TailFile is called.
+func TailFile(ctx context.Context, path string, opts TailOptions) (*Tail, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 250 * time.Millisecond
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := tailStart(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t := &Tail{path: path, opts: opts, file: file, out: make(chan TailLine, opts.Buffer), done: make(chan struct{}), offset: offset}
+	t.Lines = t.out
+	go t.run(ctx)
+	return t, nil
+}
+
+// Offset returns the position after the last line delivered.
+func (t *Tail) Offset() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.offset
+}
+
+// Err waits for the tail to stop and returns the error that stopped it: the context's error after
+// cancellation, or the read or offset-saving error that ended it.
+func (t *Tail) Err() error {
+	<-t.done
+	return t.err
+}
+
+// tailStart returns the offset to start reading file from.
+func tailStart(file *os.File, opts TailOptions) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	if opts.OffsetFile != "" {
+		saved, err := readTailOffset(opts.OffsetFile)
+		if err == nil {
+			if saved.Offset <= size && saved.matches(file, info) {
+				return saved.Offset, nil
+			}
+			return 0, nil // a different file, or one truncated since: it has not been read yet
+		}
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	switch {
+	case opts.Offset > 0:
+		return min(opts.Offset, size), nil
+	case opts.FromStart:
+		return 0, nil
+	case opts.Lines > 0:
+		return lastLinesOffset(file, size, opts.Lines)
+	}
+	return size, nil
+}
+
+// lastLinesOffset returns the offset of the start of the last n lines of file.
+func lastLinesOffset(file *os.File, size int64, n int) (int64, error) {
+	buf := make([]byte, 32*1024)
+	end := size
+	// A newline ending the file terminates the last line rather than starting a new one.
+	skip := true
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+			if skip && start+int64(i) == size-1 {
+				continue
+			}
+			n--
+			if n == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		skip = false
+		end = start
+	}
+	return 0, nil
+}
+
+// tailOffset is the content of TailOptions.OffsetFile.
+type tailOffset struct {
+	Offset      int64  `json:"offset"`
+	Fingerprint string `json:"fingerprint"` // see tailFingerprint
+	Device      uint64 `json:"device,omitempty"`
+	Inode       uint64 `json:"inode,omitempty"`
+}
+
+func newTailOffset(file *os.File, offset int64) (*tailOffset, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	saved := &tailOffset{Offset: offset, Fingerprint: tailFingerprint(file, offset)}
+	saved.Device, saved.Inode, _ = fileID(info)
+	return saved, nil
+}
+
+// matches reports whether file, described by info, is the one the offset was saved for.
+func (o *tailOffset) matches(file *os.File, info os.FileInfo) bool {
+	if device, inode, ok := fileID(info); ok && o.Inode != 0 && (device != o.Device || inode != o.Inode) {
+		return false
+	}
+	return tailFingerprint(file, o.Offset) == o.Fingerprint
+}
+
+func readTailOffset(path string) (tailOffset, error) {
+	var saved tailOffset
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return saved, err
+	}
+	err = json.Unmarshal(data, &saved)
+	return saved, err
+}
+
+// tailFingerprint hashes the first bytes of file and the bytes just before offset, so that a file
+// truncated in place and grown again past offset is not mistaken for the one that was read.
+func tailFingerprint(file *os.File, offset int64) string {
+	hash := sha256.New()
+	buf := make([]byte, min(offset, tailFingerprintLen))
+	n, _ := file.ReadAt(buf, 0)
+	hash.Write(buf[:n])
+	n, _ = file.ReadAt(buf, offset-int64(len(buf)))
+	hash.Write(buf[:n])
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (t *Tail) run(ctx context.Context) {
+	defer close(t.done)
+	defer close(t.out)
+	defer func() { t.file.Close() }()
+
+	err := t.follow(ctx)
+	if saveErr := t.saveOffset(); saveErr != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		err = saveErr
+	}
+	t.err = err
+}
+
+// follow reads the file until ctx is done, switching to a new file at path when it is rotated.
+func (t *Tail) follow(ctx context.Context) error {
+	reader := bufio.NewReader(t.file)
+	var partial []byte
+	ticker := time.NewTicker(t.opts.PollInterval)
+	defer ticker.Stop()
+
+	// next reads what is available and delivers complete lines.
+	next := func() error {
+		for {
+			chunk, err := reader.ReadBytes('\n')
+			partial = append(partial, chunk...)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := t.deliver(ctx, partial); err != nil {
+				return err
+			}
+			partial = partial[:0]
+		}
+	}
+
+	var read *tailOffset // what has been read of the current file, to detect it being rewritten
+	var modTime time.Time
+	for {
+		if err := next(); err != nil {
+			return err
+		}
+		if read == nil || read.Offset != t.Offset() {
+			var err error
+			if read, err = newTailOffset(t.file, t.Offset()); err != nil {
+				return err
+			}
+		}
+		if err := t.saveOffset(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := t.file.Stat()
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(t.path)
+		if os.IsNotExist(err) {
+			continue // removed: keep reading the old file until a new one appears
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case !os.SameFile(current, info):
+			// Rotated: finish the old file, then move on to the new one.
+			if err := next(); err != nil {
+				return err
+			}
+			if len(partial) > 0 {
+				if err := t.deliver(ctx, partial); err != nil {
+					return err
+				}
+				partial = partial[:0]
+			}
+			file, err := os.Open(t.path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			t.file.Close()
+			t.file = file
+			reader.Reset(file)
+			t.setOffset(0)
+			read, t.saved = nil, nil
+		case info.Size() < t.Offset()+int64(len(partial)),
+			!info.ModTime().Equal(modTime) && tailFingerprint(t.file, read.Offset) != read.Fingerprint:
+			// Truncated in place, possibly growing past the old position since: start again from the beginning.
+			if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			reader.Reset(t.file)
+			partial = partial[:0]
+			t.setOffset(0)
+			read = nil
+		}
+		modTime = info.ModTime()
+	}
+}
+
+func (t *Tail) deliver(ctx context.Context, line []byte) error {
+	offset := t.Offset() + int64(len(line))
+	text := strings.TrimSuffix(string(bytes.TrimSuffix(line, []byte("\n"))), "\r")
+	select {
+	case t.out <- TailLine{Text: text, Offset: offset}:
+		t.setOffset(offset)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tail) setOffset(offset int64) {
+	t.mu.Lock()
+	t.offset = offset
+	t.mu.Unlock()
+}
+
+// saveOffset writes the current position to OffsetFile, if set and if it changed since the last save.
+func (t *Tail) saveOffset() error {
+	if t.opts.OffsetFile == "" {
+		return nil
+	}
+	offset := t.Offset()
+	if t.saved != nil && t.saved.Offset == offset {
+		return nil
+	}
+	saved, err := newTailOffset(t.file, offset)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(t.opts.OffsetFile, data, 0644); err != nil {
+		return err
+	}
+	t.saved = saved
+	return nil
+}
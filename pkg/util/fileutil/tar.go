@@ -0,0 +1,222 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TarFiles bundles one or more files into an uncompressed tar archive.
+// File modes, modification times and symlinks are preserved.
+func TarFiles(filename string, files []string) error {
+	return createTar(filename, files, false)
+}
+
+// TarGzFiles bundles one or more files into a gzip-compressed tar archive.
+// File modes, modification times and symlinks are preserved.
+func TarGzFiles(filename string, files []string) error {
+	return createTar(filename, files, true)
+}
+
+func createTar(filename string, files []string, compress bool) error {
+	newTarFile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer newTarFile.Close()
+
+	var w io.Writer = newTarFile
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(newTarFile)
+		w = gzipWriter
+	}
+
+	tarWriter := tar.NewWriter(w)
+	for _, file := range files {
+		if err = addFileToTar(tarWriter, file); err != nil {
+			return err
+		}
+	}
+	if err = tarWriter.Close(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		if err = gzipWriter.Close(); err != nil {
+			return err
+		}
+	}
+	return newTarFile.Close()
+}
+
+func addFileToTar(tarWriter *tar.Writer, filename string) error {
+	info, err := os.Lstat(filename)
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(filename); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	// Like tar(1), store names relative by dropping any leading separator.
+	header.Name = strings.TrimLeft(filepath.ToSlash(filename), "/")
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	fileToTar, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fileToTar.Close()
+
+	_, err = io.Copy(tarWriter, fileToTar)
+	return err
+}
+
+// Untar extracts a tar or tar.gz archive into dest, detecting gzip compression automatically.
+// Entries are validated against DefaultExtractLimits; use UntarWithLimits to tune them.
+func Untar(src, dest string) ([]string, error) {
+	return UntarWithLimits(src, dest, DefaultExtractLimits())
+}
+
+// UntarWithLimits extracts a tar or tar.gz archive into dest with the same safety rules as UnzipWithLimits.
+// Permission bits (regardless of the umask), modification times, symlinks and hard links are restored;
+// metadata-only entries such as pax global headers are skipped.
+func UntarWithLimits(src, dest string, limits ExtractLimits) ([]string, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	x, err := newExtractor(dest, limits)
+	if err != nil {
+		return nil, err
+	}
+	return untar(x, file)
+}
+
+// untar extracts the tar stream in r, transparently decompressing gzip input.
+func untar(x *extractor, r io.Reader) ([]string, error) {
+	var filenames []string
+
+	compressed := &countingReader{r: r}
+	br := bufio.NewReader(compressed)
+	var tr *tar.Reader
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(br)
+		if err != nil {
+			return filenames, err
+		}
+		defer gzipReader.Close()
+		// Entry sizes inside a compressed stream are unknown, so the ratio is checked over the whole stream.
+		x.source = compressed
+		tr = tar.NewReader(gzipReader)
+	} else {
+		tr = tar.NewReader(br)
+	}
+
+	type dirAttrs struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirAttrs
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return filenames, err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader || header.Typeflag == tarTypeVolumeLabel {
+			continue // metadata only, such as the pax_global_header written by git archive
+		}
+		if err := x.addEntry(header.Name); err != nil {
+			return filenames, err
+		}
+
+		fpath, err := x.resolve(header.Name)
+		if err != nil {
+			return filenames, err
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = x.mkdir(fpath, mode); err == nil {
+				dirs = append(dirs, dirAttrs{fpath, mode, header.ModTime})
+			}
+		case tar.TypeReg:
+			if err = x.writeFile(header.Name, fpath, tr, mode, 0); err == nil {
+				// Chmod, unlike the create, is not masked by the umask and also applies to existing files.
+				err = os.Chmod(fpath, mode)
+			}
+			if err == nil {
+				err = os.Chtimes(fpath, header.ModTime, header.ModTime)
+			}
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, fpath, header.Linkname)
+		case tar.TypeLink:
+			err = x.hardlink(header.Name, fpath, header.Linkname)
+		default:
+			err = &ExtractError{Rule: RuleUnsupportedEntry, Entry: header.Name, Detail: fmt.Sprintf("type %q", header.Typeflag)}
+		}
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, x.display(fpath))
+		x.entryDone(header.Name)
+	}
+
+	// Directory modes and times are restored last, since extracting their contents needs write
+	// access and updates the times.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return filenames, err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return filenames, err
+		}
+	}
+	return filenames, nil
+}
+
+// tarTypeVolumeLabel is the GNU volume header entry type, which archive/tar has no constant for.
+const tarTypeVolumeLabel = 'V'
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,300 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TextEncoding identifies the encoding announced by a byte order mark.
+type TextEncoding int
+
+const (
+	EncodingUnknown TextEncoding = iota // no byte order mark
+	EncodingUTF8
+	EncodingUTF16LE
+	EncodingUTF16BE
+)
+
+// String returns the conventional name of the encoding.
+func (e TextEncoding) String() string {
+	switch e {
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingUTF16LE:
+		return "UTF-16LE"
+	case EncodingUTF16BE:
+		return "UTF-16BE"
+	}
+	return "unknown"
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DetectBOM reads the byte order mark at the start of r, if any. It returns the encoding it announces
+// and a reader for the rest of the content with the mark removed. Without a mark the encoding is
+// EncodingUnknown and the returned reader yields the content unchanged.
+func DetectBOM(r io.Reader) (TextEncoding, io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return EncodingUnknown, nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		br.Discard(len(bomUTF8))
+		return EncodingUTF8, br, nil
+	case bytes.HasPrefix(head, bomUTF16LE):
+		br.Discard(len(bomUTF16LE))
+		return EncodingUTF16LE, br, nil
+	case bytes.HasPrefix(head, bomUTF16BE):
+		br.Discard(len(bomUTF16BE))
+		return EncodingUTF16BE, br, nil
+	}
+	return EncodingUnknown, br, nil
+}
+
+// NewUTF8Reader returns a reader that yields the content of r as UTF-8 without a byte order mark.
+// UTF-16 content marked with a BOM is transcoded; anything else is passed through unchanged.
+func NewUTF8Reader(r io.Reader) (io.Reader, error) {
+	enc, body, err := DetectBOM(r)
+	if err != nil {
+		return nil, err
+	}
+	switch enc {
+	case EncodingUTF16LE:
+		return NewUTF16Reader(body, false), nil
+	case EncodingUTF16BE:
+		return NewUTF16Reader(body, true), nil
+	}
+	return body, nil
+}
+
+// NewUTF16Reader returns a reader that transcodes UTF-16 from r to UTF-8. Unpaired surrogates and a
+// trailing odd byte are replaced by U+FFFD. A byte order mark in r is not interpreted; use
+// NewUTF8Reader to detect one.
+func NewUTF16Reader(r io.Reader, bigEndian bool) io.Reader {
+	return &utf16Reader{r: bufio.NewReader(r), bigEndian: bigEndian}
+}
+
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	pending   uint16 // code unit read ahead while looking for a low surrogate
+	hasUnit   bool
+	buf       [utf8.UTFMax]byte
+	out       []byte // encoded bytes of buf not yet returned
+	err       error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(u.out) > 0 {
+			c := copy(p[n:], u.out)
+			u.out = u.out[c:]
+			n += c
+			continue
+		}
+		if u.err != nil {
+			break
+		}
+		r, err := u.next()
+		if err != nil {
+			u.err = err
+			continue
+		}
+		u.out = u.buf[:utf8.EncodeRune(u.buf[:], r)]
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, u.err
+}
+
+// next decodes one rune, combining surrogate pairs.
+func (u *utf16Reader) next() (rune, error) {
+	unit, err := u.unit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(unit)) {
+		return rune(unit), nil
+	}
+	if unit >= 0xDC00 {
+		return utf8.RuneError, nil // low surrogate without a high one
+	}
+	low, err := u.unit()
+	if err == io.EOF {
+		return utf8.RuneError, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if r := utf16.DecodeRune(rune(unit), rune(low)); r != utf8.RuneError {
+		return r, nil
+	}
+	// Not a low surrogate: keep it for the next rune.
+	u.pending, u.hasUnit = low, true
+	return utf8.RuneError, nil
+}
+
+func (u *utf16Reader) unit() (uint16, error) {
+	if u.hasUnit {
+		u.hasUnit = false
+		return u.pending, nil
+	}
+	b0, err := u.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	b1, err := u.r.ReadByte()
+	if err == io.EOF {
+		return uint16(utf8.RuneError), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if u.bigEndian {
+		return uint16(b0)<<8 | uint16(b1), nil
+	}
+	return uint16(b1)<<8 | uint16(b0), nil
+}
+
+// NewLatin1Reader returns a reader that transcodes ISO-8859-1 from r to UTF-8.
+func NewLatin1Reader(r io.Reader) io.Reader {
+	return &latin1Reader{r: bufio.NewReader(r)}
+}
+
+type latin1Reader struct {
+	r   *bufio.Reader
+	buf [2]byte
+	out []byte // encoded bytes of buf not yet returned
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(l.out) > 0 {
+			c := copy(p[n:], l.out)
+			l.out = l.out[c:]
+			n += c
+			continue
+		}
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+		l.out = l.buf[:utf8.EncodeRune(l.buf[:], rune(b))]
+	}
+	return n, nil
+}
+
+// UnixToDos copies r to w, converting LF line endings to CRLF. Existing CRLF endings are left alone.
+func UnixToDos(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	br := bufio.NewReader(r)
+	var prev byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if b == '\n' && prev != '\r' {
+			bw.WriteByte('\r')
+		}
+		bw.WriteByte(b)
+		prev = b
+	}
+	return bw.Flush()
+}
+
+// DosToUnix copies r to w, converting CRLF line endings to LF. Lone CR characters are kept.
+func DosToUnix(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	br := bufio.NewReader(r)
+	pendingCR := false
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if pendingCR && b != '\n' {
+			bw.WriteByte('\r')
+		}
+		pendingCR = b == '\r'
+		if !pendingCR {
+			bw.WriteByte(b)
+		}
+	}
+	if pendingCR {
+		bw.WriteByte('\r')
+	}
+	return bw.Flush()
+}
+
+// StripBOM removes a byte order mark from the start of the file, if present.
+func StripBOM(filePath string) error {
+	return convertFile(filePath, func(w io.Writer, r io.Reader) error {
+		_, body, err := DetectBOM(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, body)
+		return err
+	})
+}
+
+// ConvertToUTF8 rewrites a file marked as UTF-16 by its byte order mark as UTF-8, and strips the BOM
+// of a UTF-8 file. Files without a BOM are left unchanged.
+func ConvertToUTF8(filePath string) error {
+	return convertFile(filePath, func(w io.Writer, r io.Reader) error {
+		body, err := NewUTF8Reader(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, body)
+		return err
+	})
+}
+
+// convertFile streams filePath through convert into a temporary file that atomically replaces it.
+func convertFile(filePath string, convert func(w io.Writer, r io.Reader) error) error {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := CreateAtomic(filePath, 0644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if err := convert(dst, src); err != nil {
+		return err
+	}
+	return dst.Commit()
+}
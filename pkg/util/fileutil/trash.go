@@ -0,0 +1,372 @@
+package fileutil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotInTrash is returned for trash item IDs that do not exist.
+var ErrNotInTrash = errors.New("fileutil: item not in trash")
+
+// TrashItem describes something moved into a Trash.
+type TrashItem struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"` // absolute
+	DeletedAt    time.Time `json:"deleted_at"`
+	Size         int64     `json:"size"` // total size of the files, for directories
+	IsDir        bool      `json:"is_dir"`
+}
+
+// Trash is a soft-delete area. Moved files and directories are kept under dir/files with a JSON
+// record under dir/info, so they can be listed, restored or purged later. Keep the trash on the same
+// filesystem as the data: moves are then plain renames, while other moves fall back to copying.
+// Helpers such as DeleteFile and RemoveDirectory move into a Trash when passed ToTrash.
+type Trash struct {
+	dir string
+}
+
+// OpenTrash opens the trash at dir, creating it if needed.
+func OpenTrash(dir string) (*Trash, error) {
+	t := &Trash{dir: dir}
+	for _, sub := range []string{t.filesDir(), t.infoDir()} {
+		if err := os.MkdirAll(sub, 0700); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *Trash) filesDir() string { return filepath.Join(t.dir, "files") }
+func (t *Trash) infoDir() string  { return filepath.Join(t.dir, "info") }
+
+func (t *Trash) itemPath(id string) string { return filepath.Join(t.filesDir(), id) }
+func (t *Trash) infoPath(id string) string { return filepath.Join(t.infoDir(), id+".json") }
+
+// Move moves path into the trash and returns its record.
+func (t *Trash) Move(path string) (*TrashItem, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if _, inside := t.locate(abs); inside && info.IsDir() {
+		return nil, fmt.Errorf("fileutil: cannot move %s into the trash inside it", abs)
+	}
+	item := &TrashItem{OriginalPath: abs, DeletedAt: time.Now().UTC(), Size: info.Size(), IsDir: info.IsDir()}
+	if info.IsDir() {
+		if item.Size, err = CalculateDirSize(abs); err != nil {
+			return nil, err
+		}
+	}
+	if item.ID, err = newTrashID(item.DeletedAt); err != nil {
+		return nil, err
+	}
+
+	// Write the record first, so that a crash never leaves an item that cannot be restored.
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteFileAtomic(t.infoPath(item.ID), data, 0600); err != nil {
+		return nil, err
+	}
+	if err := moveAcross(abs, t.itemPath(item.ID), info); err != nil {
+		os.Remove(t.infoPath(item.ID))
+		return nil, err
+	}
+	return item, nil
+}
+
+// MoveAll moves several paths into the trash, stopping at the first error,
+// as BatchRemoveFiles does for permanent deletion.
+func (t *Trash) MoveAll(paths []string) ([]TrashItem, error) {
+	var items []TrashItem
+	for _, p := range paths {
+		item, err := t.Move(p)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// DeleteOption configures the deletion helpers DeleteFile, RemoveDirectory, RemoveContents and BatchRemoveFiles.
+type DeleteOption func(*deleteConfig)
+
+type deleteConfig struct {
+	trash *Trash
+}
+
+// ToTrash makes a deletion helper move what it deletes into t, so that it can be restored later.
+func ToTrash(t *Trash) DeleteOption {
+	return func(c *deleteConfig) {
+		c.trash = t
+	}
+}
+
+func newDeleteConfig(opts []DeleteOption) deleteConfig {
+	var c deleteConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// remove deletes path like os.Remove, or moves it into the trash.
+func (c deleteConfig) remove(path string) error {
+	if c.trash == nil {
+		return os.Remove(path)
+	}
+	_, err := c.trash.Move(path)
+	return err
+}
+
+// removeAll deletes path like os.RemoveAll, or moves it into the trash. A missing path is not an error.
+func (c deleteConfig) removeAll(path string) error {
+	if c.trash == nil {
+		return os.RemoveAll(path)
+	}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	_, err := c.trash.Move(path)
+	return err
+}
+
+// newTrashID returns an ID that sorts by deletion time.
+func newTrashID(t time.Time) (string, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return t.Format("20060102T150405.000000000") + "-" + hex.EncodeToString(b[:]), nil
+}
+
+// moveAcross renames src to dst, copying and removing it when they are on different filesystems.
+func moveAcross(src, dst string, info os.FileInfo) error {
+	err := os.Rename(src, dst)
+	if !isCrossDevice(err) {
+		return err
+	}
+	if err := copyPreserving(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyPreserving copies the tree at src to dst as it is: symlinks are recreated rather than
+// followed, and modes and modification times are kept. Other special files are an error.
+func copyPreserving(src, dst string) error {
+	type copiedDir struct {
+		path string
+		info os.FileInfo
+	}
+	var dirs []copiedDir // given their mode and time once their contents are in place
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, copiedDir{target, info})
+			return os.Mkdir(target, 0700)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			return copyFilePreserving(p, target, info)
+		default:
+			return fmt.Errorf("fileutil: cannot copy %s: unsupported file type %v", p, mode.Type())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := setModeAndTime(dirs[i].path, dirs[i].info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFilePreserving(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return setModeAndTime(dst, info)
+}
+
+// setModeAndTime gives path the permission bits, including setuid, setgid and sticky, and the
+// modification time of info.
+func setModeAndTime(path string, info os.FileInfo) error {
+	if err := os.Chmod(path, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(path, time.Time{}, info.ModTime())
+}
+
+// locate returns where the trash directory is relative to path: "." if path is the trash itself, a
+// relative path if the trash lies below path, and false otherwise.
+func (t *Trash) locate(path string) (string, bool) {
+	if info, err := os.Stat(path); err == nil {
+		if trashInfo, err := os.Stat(t.dir); err == nil && os.SameFile(info, trashInfo) {
+			return ".", true
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	trashAbs, err := filepath.Abs(t.dir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(abs, trashAbs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Get returns the record of the item with the given ID.
+func (t *Trash) Get(id string) (*TrashItem, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("%w: %q", ErrNotInTrash, id)
+	}
+	data, err := os.ReadFile(t.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %q", ErrNotInTrash, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("fileutil: trash record %s: %w", id, err)
+	}
+	return &item, nil
+}
+
+// List returns the items in the trash, oldest first. Records whose content is missing are skipped.
+// Records that cannot be read or parsed are skipped too and reported in the returned error
+// alongside the other items.
+func (t *Trash) List() ([]TrashItem, error) {
+	entries, err := os.ReadDir(t.infoDir())
+	if err != nil {
+		return nil, err
+	}
+	var items []TrashItem
+	var errs []error
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		item, err := t.Get(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := os.Lstat(t.itemPath(id)); err != nil {
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, errors.Join(errs...)
+}
+
+// Restore moves the item back to its original path, recreating missing parent directories.
+// It fails if something else exists at that path by now.
+func (t *Trash) Restore(id string) error {
+	item, err := t.Get(id)
+	if err != nil {
+		return err
+	}
+	return t.RestoreTo(id, item.OriginalPath)
+}
+
+// RestoreTo moves the item to dst instead of its original path.
+func (t *Trash) RestoreTo(id, dst string) error {
+	if _, err := t.Get(id); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.PathError{Op: "restore", Path: dst, Err: os.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	src := t.itemPath(id)
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := moveAcross(src, dst, info); err != nil {
+		return err
+	}
+	return os.Remove(t.infoPath(id))
+}
+
+// Delete permanently removes an item from the trash.
+func (t *Trash) Delete(id string) error {
+	if _, err := t.Get(id); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(t.itemPath(id)); err != nil {
+		return err
+	}
+	return os.Remove(t.infoPath(id))
+}
+
+// Purge permanently removes the items selected by policy, using their deletion time as their age.
+// With policy.DryRun set it only reports what would be removed. Corrupt records are left alone and
+// reported in the returned error, as by List.
+func (t *Trash) Purge(policy RetentionPolicy) (*SweepResult, error) {
+	items, listErr := t.List()
+	if listErr != nil && len(items) == 0 {
+		return nil, listErr
+	}
+	candidates := make([]sweepCandidate, len(items))
+	for i, item := range items {
+		candidates[i] = sweepCandidate{path: item.ID, size: item.Size, modTime: item.DeletedAt}
+	}
+	result, err := sweep(candidates, policy, t.Delete)
+	return result, errors.Join(listErr, err)
+}
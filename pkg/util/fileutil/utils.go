@@ -3,11 +3,14 @@ package fileutil
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // ErrNonRegularSourceFile error returned when source file is not a regular file.
@@ -92,6 +95,7 @@ func FindFilesMatchingPattern(dirPath, pattern string) ([]string, error) {
 }
 
 // GrepFile searches for a string pattern in a file and returns the lines containing the pattern.
+// See NewGrepper for regular expressions, context lines and recursive search.
 func GrepFile(filePath string, pattern string) ([]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -157,28 +161,34 @@ func ConcatenateFiles(destPath string, filePaths []string) error {
 	return nil
 }
 
-// ListFilesRecursive lists all files in a directory and its subdirectories.
+// ListFilesRecursive lists all files in a directory and its subdirectories in filepath.Walk order.
+// The tree is walked in parallel; entries that cannot be read are skipped and reported in the
+// returned error alongside the files that were found.
 func ListFilesRecursive(dirPath string) ([]string, error) {
+	var mu sync.Mutex
 	var files []string
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() {
+	err := ParallelWalk(context.Background(), dirPath, WalkOptions{}, func(path string, d fs.DirEntry) error {
+		if !d.IsDir() {
+			mu.Lock()
 			files = append(files, path)
+			mu.Unlock()
 		}
 		return nil
 	})
+	sortWalkOrder(files, filepath.Separator)
 	return files, err
 }
 
 // ConvertUnixToDos converts Unix line endings (LF) to DOS/Windows line endings (CRLF).
+// The file is streamed through a temporary file that atomically replaces it.
 func ConvertUnixToDos(filePath string) error {
-	// omitted for brevity - implement conversion from LF to CRLF
-	return nil
+	return convertFile(filePath, UnixToDos)
 }
 
 // ConvertDosToUnix converts DOS/Windows line endings (CRLF) to Unix line endings (LF).
+// The file is streamed through a temporary file that atomically replaces it.
 func ConvertDosToUnix(filePath string) error {
-	// omitted for brevity - implement conversion from CRLF to LF
-	return nil
+	return convertFile(filePath, DosToUnix)
 }
 
 // ChownRecursive changes the owner and group of the specified directory and all its sub-content.
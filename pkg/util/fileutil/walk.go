@@ -0,0 +1,326 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// WalkOptions configures ParallelWalk.
+type WalkOptions struct {
+	// Workers bounds the number of goroutines reading directories and running the callback.
+	// It defaults to twice GOMAXPROCS, since walking is mostly I/O bound.
+	Workers int
+	// OnError is called for every entry that cannot be read. Returning nil skips the entry and
+	// carries on; returning an error stops the walk with that error. When OnError is nil,
+	// errors are collected and returned together once the walk has finished.
+	OnError func(path string, err error) error
+}
+
+// ParallelWalk walks the tree rooted at root with a bounded pool of goroutines, calling fn for every
+// file and directory, root included. fn runs concurrently and in no particular order, so it must be
+// safe for concurrent use. Returning filepath.SkipDir from fn for a directory skips its contents; any
+// other error stops the walk. Symlinks are reported but not followed. The walk stops early when ctx
+// is cancelled.
+func ParallelWalk(ctx context.Context, root string, opts WalkOptions, fn func(path string, d fs.DirEntry) error) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return err
+	}
+	if err := fn(root, fs.FileInfoToDirEntry(info)); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	osPath := func(name string) string { return filepath.Join(root, filepath.FromSlash(name)) }
+	if onError := opts.OnError; onError != nil {
+		opts.OnError = func(name string, err error) error { return onError(osPath(name), err) }
+	}
+	return walkTree(ctx, OSFS(root), ".", opts, func(name string, d fs.DirEntry) error {
+		return fn(osPath(name), d)
+	})
+}
+
+// ParallelWalkFS is ParallelWalk for the tree of fsys rooted at root. Paths passed to fn are
+// slash-separated names in fsys, as with fs.WalkDir.
+func ParallelWalkFS(ctx context.Context, fsys fs.FS, root string, opts WalkOptions, fn func(path string, d fs.DirEntry) error) error {
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return err
+	}
+	if err := fn(root, fs.FileInfoToDirEntry(info)); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	return walkTree(ctx, fsys, root, opts, fn)
+}
+
+// walkTree calls fn for everything below the directory root of fsys, root itself excluded.
+func walkTree(ctx context.Context, fsys fs.FS, root string, opts WalkOptions, fn func(path string, d fs.DirEntry) error) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 2 * runtime.GOMAXPROCS(0)
+	}
+
+	w := &parallelWalker{ctx: ctx, fsys: fsys, opts: opts, fn: fn}
+	w.cond = sync.NewCond(&w.mu)
+	w.queue = []string{root}
+	w.pending = 1
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+
+	// Wake the workers if the context is cancelled while they wait for work.
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.abort(ctx.Err())
+		case <-stop:
+		}
+	}()
+	wg.Wait()
+	close(stop)
+
+	if w.err != nil {
+		return w.err
+	}
+	return errors.Join(w.errs...)
+}
+
+// parallelWalker is a shared queue of directories still to be read.
+type parallelWalker struct {
+	ctx  context.Context
+	fsys fs.FS
+	opts WalkOptions
+	fn   func(path string, d fs.DirEntry) error
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []string
+	pending int     // directories queued or being read
+	err     error   // error that stopped the walk
+	errs    []error // collected entry errors
+}
+
+func (w *parallelWalker) work() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.pending > 0 && w.err == nil {
+			w.cond.Wait()
+		}
+		if w.pending == 0 || w.err != nil {
+			w.mu.Unlock()
+			return
+		}
+		dir := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		w.mu.Unlock()
+
+		subdirs := w.readDir(dir)
+
+		w.mu.Lock()
+		w.queue = append(w.queue, subdirs...)
+		w.pending += len(subdirs) - 1
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// readDir calls fn for the entries of dir and returns the subdirectories to descend into.
+func (w *parallelWalker) readDir(dir string) []string {
+	if err := w.ctx.Err(); err != nil {
+		w.abort(err)
+		return nil
+	}
+	entries, err := fs.ReadDir(w.fsys, dir)
+	if err != nil {
+		w.entryError(dir, err)
+		// ReadDir returns the entries it managed to read before the error.
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		err := w.fn(p, entry)
+		if err == filepath.SkipDir {
+			continue
+		}
+		if err != nil {
+			w.abort(err)
+			return nil
+		}
+		if entry.IsDir() {
+			subdirs = append(subdirs, p)
+		}
+	}
+	return subdirs
+}
+
+func (w *parallelWalker) entryError(path string, err error) {
+	if w.opts.OnError != nil {
+		if stop := w.opts.OnError(path, err); stop != nil {
+			w.abort(stop)
+		}
+		return
+	}
+	w.mu.Lock()
+	w.errs = append(w.errs, err)
+	w.mu.Unlock()
+}
+
+func (w *parallelWalker) abort(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+// sortWalkOrder sorts paths into the order filepath.Walk visits them: lexically element by element,
+// so that "a/b" comes before "a-b" even though '-' sorts before '/'. sep separates the elements.
+func sortWalkOrder(paths []string, sep byte) {
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := paths[i], paths[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] == b[k] {
+				continue
+			}
+			if a[k] == sep || b[k] == sep {
+				return a[k] == sep
+			}
+			return a[k] < b[k]
+		}
+		return len(a) < len(b)
+	})
+}
+
+// FileHash is the digest of one file found by HashTree.
+type FileHash struct {
+	Path   string // slash-separated, relative to the tree root
+	Size   int64
+	Mode   os.FileMode
+	SHA256 string // lowercase hex, as returned by CalculateFileSHA256
+}
+
+// HashTree computes the SHA-256 digest of every regular file below root in parallel and returns
+// the results sorted by path. The walk only lists files; they are hashed by a separate pool of
+// opts.Workers goroutines, so a directory full of large files is spread across all of them.
+// Unreadable files are reported through opts.OnError or in the returned error, like ParallelWalk,
+// while the remaining files are still hashed.
+func HashTree(ctx context.Context, root string, opts WalkOptions) ([]FileHash, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 2 * runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var mu sync.Mutex
+	var hashes []FileHash
+	var errs []error
+	report := func(path string, err error) {
+		if opts.OnError != nil {
+			if stop := opts.OnError(path, err); stop != nil {
+				cancel(stop)
+			}
+			return
+		}
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	files := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range files {
+				h, err := hashFile(path)
+				if err == nil {
+					h.Path, err = hashTreePath(root, path)
+				}
+				if err != nil {
+					report(path, err)
+					continue
+				}
+				mu.Lock()
+				hashes = append(hashes, h)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := ParallelWalk(ctx, root, opts, func(path string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		select {
+		case files <- path:
+			return nil
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	})
+	close(files)
+	wg.Wait()
+	if cause := context.Cause(ctx); cause != nil {
+		err = cause // cancelled by the caller or stopped by OnError while hashing
+	}
+
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Path < hashes[j].Path })
+	if err == nil {
+		err = errors.Join(errs...)
+	} else if len(errs) > 0 {
+		err = errors.Join(append([]error{err}, errs...)...)
+	}
+	return hashes, err
+}
+
+// hashTreePath returns the slash-separated path of a file found by HashTree relative to root.
+func hashTreePath(root, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		rel = filepath.Base(path) // root is itself a file
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func hashFile(path string) (FileHash, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileHash{}, err
+	}
+	digest, err := CalculateFileSHA256(path)
+	if err != nil {
+		return FileHash{}, err
+	}
+	return FileHash{Size: info.Size(), Mode: info.Mode(), SHA256: digest}, nil
+}
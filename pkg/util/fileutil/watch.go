@@ -0,0 +1,344 @@
+package fileutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op describes the kind of change a WatchEvent reports. Debounced events may combine several.
+type Op uint32
+
+// Watch operations.
+const (
+	OpCreate Op = 1 << iota
+	OpWrite
+	OpRemove
+	OpRename
+	OpChmod
+)
+
+// String returns the operations in op joined by "|", e.g. "CREATE|WRITE".
+func (op Op) String() string {
+	var names []string
+	for _, n := range []struct {
+		op   Op
+		name string
+	}{{OpCreate, "CREATE"}, {OpWrite, "WRITE"}, {OpRemove, "REMOVE"}, {OpRename, "RENAME"}, {OpChmod, "CHMOD"}} {
+		if op&n.op != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, "|")
+}
+
+// WatchEvent is a change to a watched path.
+type WatchEvent struct {
+	Path string
+	Op   Op
+}
+
+// ErrEventOverflow is reported on Watcher.Errors when the kernel dropped events; callers should rescan.
+var ErrEventOverflow = errors.New("fileutil: watch event queue overflowed")
+
+// WatchOptions configures a Watcher.
+type WatchOptions struct {
+	// Recursive watches the whole tree below directories passed to Add, including directories created later.
+	Recursive bool
+	// Debounce coalesces bursts of events: changes to the same path are merged and delivered once the
+	// tree has been quiet for this long. Zero delivers every event as it arrives.
+	Debounce time.Duration
+	// PollInterval is how often the polling fallback rescans. It defaults to one second.
+	PollInterval time.Duration
+	// ForcePolling skips inotify and always polls, e.g. for network filesystems that do not report changes.
+	ForcePolling bool
+}
+
+// Watcher reports changes to files and directory trees. It uses inotify on Linux and falls back to
+// polling where inotify is unavailable. Watching a single file watches its directory, so that files
+// replaced by rename, as editors and WriteFileAtomic do, keep being reported.
+type Watcher struct {
+	Events <-chan WatchEvent
+	Errors <-chan error
+
+	backend   watchBackend
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// watchBackend produces raw events. Its goroutine closes the event and error channels when it exits.
+type watchBackend interface {
+	add(path string) error
+	remove(path string) error
+	close() error
+}
+
+// NewWatcher creates a Watcher. Call Add to start watching paths and Close to release it.
+func NewWatcher(opts WatchOptions) (*Watcher, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	raw := make(chan WatchEvent, 128)
+	errs := make(chan error, 16)
+	events := make(chan WatchEvent, 128)
+	w := &Watcher{Events: events, Errors: errs, done: make(chan struct{})}
+
+	var err error
+	if !opts.ForcePolling {
+		w.backend, err = newInotifyBackend(opts.Recursive, raw, errs, w.done, &w.wg)
+	}
+	if opts.ForcePolling || err != nil {
+		w.backend = newPollBackend(opts.Recursive, opts.PollInterval, raw, errs, w.done, &w.wg)
+	}
+
+	w.wg.Add(1)
+	go w.debounce(raw, events, opts.Debounce)
+	return w, nil
+}
+
+// Add starts watching path, which may be a file or a directory.
+func (w *Watcher) Add(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	return w.backend.add(abs)
+}
+
+// Remove stops watching path.
+func (w *Watcher) Remove(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	return w.backend.remove(abs)
+}
+
+// Close stops the watcher and closes the Events and Errors channels.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.backend.close()
+		w.wg.Wait()
+	})
+	return err
+}
+
+// debounce forwards raw events to out, merging bursts when delay is positive.
+// A steady stream of events is still flushed at least every ten delays.
+func (w *Watcher) debounce(raw <-chan WatchEvent, out chan<- WatchEvent, delay time.Duration) {
+	defer w.wg.Done()
+	defer close(out)
+
+	pending := make(map[string]Op)
+	var order []string
+	var first time.Time
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	send := func(ev WatchEvent) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-w.done:
+			return false
+		}
+	}
+	flush := func() bool {
+		for _, p := range order {
+			if !send(WatchEvent{Path: p, Op: pending[p]}) {
+				return false
+			}
+			delete(pending, p)
+		}
+		order = order[:0]
+		return true
+	}
+
+	for {
+		select {
+		case ev, ok := <-raw:
+			if !ok {
+				flush()
+				return
+			}
+			if delay <= 0 {
+				if !send(ev) {
+					return
+				}
+				continue
+			}
+			if len(order) == 0 {
+				first = time.Now()
+			}
+			if _, seen := pending[ev.Path]; !seen {
+				order = append(order, ev.Path)
+			}
+			pending[ev.Path] |= ev.Op
+			wait := delay
+			if maxWait := first.Add(10 * delay).Sub(time.Now()); maxWait < wait {
+				wait = maxWait
+			}
+			timer.Stop()
+			timer.Reset(wait)
+		case <-timer.C:
+			if !flush() {
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// emit delivers an event or error from a backend unless the watcher is closing.
+func emit(events chan<- WatchEvent, done <-chan struct{}, ev WatchEvent) {
+	select {
+	case events <- ev:
+	case <-done:
+	}
+}
+
+func emitError(errs chan<- error, done <-chan struct{}, err error) {
+	select {
+	case errs <- err:
+	case <-done:
+	}
+}
+
+// pollBackend detects changes by periodically comparing snapshots of the watched paths.
+// It cannot tell renames apart, which are reported as OpRemove and OpCreate.
+type pollBackend struct {
+	recursive bool
+	mu        sync.Mutex
+	roots     map[string]bool
+	state     map[string]os.FileInfo
+}
+
+func newPollBackend(recursive bool, interval time.Duration, events chan<- WatchEvent, errs chan<- error, done <-chan struct{}, wg *sync.WaitGroup) *pollBackend {
+	p := &pollBackend{recursive: recursive, roots: make(map[string]bool), state: make(map[string]os.FileInfo)}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(events)
+		defer close(errs)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.poll(events, errs, done)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *pollBackend) add(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roots[path] = true
+	snapshot, _ := p.scan(path)
+	for k, v := range snapshot {
+		p.state[k] = v
+	}
+	return nil
+}
+
+func (p *pollBackend) remove(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.roots, path)
+	for k := range p.state {
+		if k == path || strings.HasPrefix(k, path+string(filepath.Separator)) {
+			delete(p.state, k)
+		}
+	}
+	return nil
+}
+
+func (p *pollBackend) close() error {
+	return nil
+}
+
+// scan records the current state of root and, for directories, its entries.
+func (p *pollBackend) scan(root string) (map[string]os.FileInfo, error) {
+	snapshot := make(map[string]os.FileInfo)
+	info, err := os.Stat(root)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot[root] = info
+	if !info.IsDir() {
+		return snapshot, nil
+	}
+	if !p.recursive {
+		entries, err := ioutil.ReadDir(root)
+		for _, entry := range entries {
+			snapshot[filepath.Join(root, entry.Name())] = entry
+		}
+		return snapshot, err
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // entries vanishing mid-scan show up as removals next time
+		}
+		snapshot[path] = info
+		return nil
+	})
+	return snapshot, err
+}
+
+func (p *pollBackend) poll(events chan<- WatchEvent, errs chan<- error, done <-chan struct{}) {
+	p.mu.Lock()
+	current := make(map[string]os.FileInfo)
+	var scanErr error
+	for root := range p.roots {
+		snapshot, err := p.scan(root)
+		if err != nil && !os.IsNotExist(err) && scanErr == nil {
+			scanErr = err
+		}
+		for k, v := range snapshot {
+			current[k] = v
+		}
+	}
+	previous := p.state
+	p.state = current
+	p.mu.Unlock()
+
+	if scanErr != nil {
+		emitError(errs, done, scanErr)
+	}
+	for path, info := range current {
+		old, existed := previous[path]
+		switch {
+		case !existed:
+			emit(events, done, WatchEvent{Path: path, Op: OpCreate})
+		case !info.IsDir() && (info.Size() != old.Size() || !info.ModTime().Equal(old.ModTime())):
+			emit(events, done, WatchEvent{Path: path, Op: OpWrite})
+		case info.Mode() != old.Mode():
+			emit(events, done, WatchEvent{Path: path, Op: OpChmod})
+		}
+	}
+	for path := range previous {
+		if _, exists := current[path]; !exists {
+			emit(events, done, WatchEvent{Path: path, Op: OpRemove})
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_DELETE |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_MOVE_SELF
+
+// inotifyBackend watches directories with inotify. Files are watched through their parent
+// directory and filtered by name.
+type inotifyBackend struct {
+	file      *os.File
+	fd        int
+	recursive bool
+	events    chan<- WatchEvent
+	errs      chan<- error
+	done      <-chan struct{}
+
+	mu    sync.Mutex
+	paths map[int]string             // watch descriptor -> directory
+	wds   map[string]int             // directory -> watch descriptor
+	dirs  map[string]bool            // directories whose every entry is reported
+	roots map[string]bool            // directories passed to add
+	files map[string]map[string]bool // directory -> individually watched file names
+}
+
+func newInotifyBackend(recursive bool, events chan<- WatchEvent, errs chan<- error, done <-chan struct{}, wg *sync.WaitGroup) (*inotifyBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	b := &inotifyBackend{
+		// A non-blocking descriptor is served by the runtime poller, so closing the file unblocks Read.
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		fd:        fd,
+		recursive: recursive,
+		events:    events,
+		errs:      errs,
+		done:      done,
+		paths:     make(map[int]string),
+		wds:       make(map[string]int),
+		dirs:      make(map[string]bool),
+		roots:     make(map[string]bool),
+		files:     make(map[string]map[string]bool),
+	}
+	wg.Add(1)
+	go b.run(wg)
+	return b, nil
+}
+
+func (b *inotifyBackend) add(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !info.IsDir() {
+		dir, name := filepath.Split(path)
+		dir = filepath.Clean(dir)
+		if err := b.watch(dir); err != nil {
+			return err
+		}
+		if b.files[dir] == nil {
+			b.files[dir] = make(map[string]bool)
+		}
+		b.files[dir][name] = true
+		return nil
+	}
+
+	b.roots[path] = true
+	_, err = b.addTree(path)
+	return err
+}
+
+// addTree watches dir and, in recursive mode, every directory below it. It returns the paths
+// found below dir so that entries created before the watch was in place can be reported.
+// The caller must hold b.mu.
+func (b *inotifyBackend) addTree(dir string) ([]string, error) {
+	if !b.recursive {
+		b.dirs[dir] = true
+		return nil, b.watch(dir)
+	}
+	var found []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path != dir {
+			found = append(found, path)
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		b.dirs[path] = true
+		return b.watch(path)
+	})
+	return found, err
+}
+
+// watch adds an inotify watch for dir. The caller must hold b.mu.
+func (b *inotifyBackend) watch(dir string) error {
+	wd, err := syscall.InotifyAddWatch(b.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	b.paths[wd] = dir
+	b.wds[dir] = wd
+	return nil
+}
+
+func (b *inotifyBackend) remove(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.roots[path] {
+		delete(b.roots, path)
+		for dir := range b.dirs {
+			if dir == path || strings.HasPrefix(dir, path+string(filepath.Separator)) {
+				delete(b.dirs, dir)
+				b.unwatchIfUnused(dir)
+			}
+		}
+		return nil
+	}
+
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if !b.files[dir][name] {
+		return errors.New("fileutil: path is not watched: " + path)
+	}
+	delete(b.files[dir], name)
+	if len(b.files[dir]) == 0 {
+		delete(b.files, dir)
+	}
+	b.unwatchIfUnused(dir)
+	return nil
+}
+
+// unwatchIfUnused drops the watch on dir once nothing needs it. The caller must hold b.mu.
+func (b *inotifyBackend) unwatchIfUnused(dir string) {
+	if b.dirs[dir] || len(b.files[dir]) > 0 {
+		return
+	}
+	if wd, ok := b.wds[dir]; ok {
+		syscall.InotifyRmWatch(b.fd, uint32(wd))
+		delete(b.wds, dir)
+		delete(b.paths, wd)
+	}
+}
+
+// prune removes the watches on dir and every directory below it, along with their bookkeeping.
+// The caller must hold b.mu.
+func (b *inotifyBackend) prune(dir string) {
+	for d, wd := range b.wds {
+		if d == dir || strings.HasPrefix(d, dir+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(b.fd, uint32(wd)) // fails harmlessly if the kernel already dropped it
+			delete(b.wds, d)
+			delete(b.paths, wd)
+			delete(b.dirs, d)
+			delete(b.files, d)
+			delete(b.roots, d)
+		}
+	}
+}
+
+func (b *inotifyBackend) close() error {
+	return b.file.Close()
+}
+
+func (b *inotifyBackend) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(b.events)
+	defer close(b.errs)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				emitError(b.errs, b.done, err)
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			name := strings.TrimRight(string(nameBytes), "\x00")
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				emitError(b.errs, b.done, ErrEventOverflow)
+				continue
+			}
+			b.handle(int(raw.Wd), raw.Mask, name)
+		}
+	}
+}
+
+// handle translates one inotify event into WatchEvents.
+func (b *inotifyBackend) handle(wd int, mask uint32, name string) {
+	b.mu.Lock()
+	dir, ok := b.paths[wd]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(b.paths, wd)
+		if b.wds[dir] == wd {
+			delete(b.wds, dir)
+		}
+		b.mu.Unlock()
+		return
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	var wanted bool
+	if name == "" {
+		// Events on the directory itself are only interesting for the roots; for subdirectories
+		// the parent already reported the change.
+		wanted = b.roots[dir]
+	} else {
+		wanted = b.dirs[dir] || b.files[dir][name]
+	}
+
+	// A directory that was moved away or deleted takes its watches along; drop them, or the kernel
+	// keeps reporting the moved tree under its old paths.
+	switch {
+	case name != "" && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_MOVED_FROM|syscall.IN_DELETE) != 0:
+		b.prune(path)
+	case name == "" && mask&(syscall.IN_MOVE_SELF|syscall.IN_DELETE_SELF) != 0:
+		b.prune(dir)
+	}
+
+	var created []string
+	if wanted && b.recursive && b.dirs[dir] && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		var err error
+		created, err = b.addTree(path)
+		if err != nil {
+			defer emitError(b.errs, b.done, err)
+		}
+	}
+	b.mu.Unlock()
+
+	if !wanted {
+		return
+	}
+	var op Op
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		op = OpCreate
+	case mask&syscall.IN_MODIFY != 0:
+		op = OpWrite
+	case mask&syscall.IN_ATTRIB != 0:
+		op = OpChmod
+	case mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0:
+		op = OpRemove
+	case mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0:
+		op = OpRename
+	default:
+		return
+	}
+	emit(b.events, b.done, WatchEvent{Path: path, Op: op})
+	// Entries created inside a new directory before its watch existed.
+	for _, p := range created {
+		emit(b.events, b.done, WatchEvent{Path: p, Op: OpCreate})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fileutil
+
+import (
+	"errors"
+	"sync"
+)
+
+// newInotifyBackend reports that inotify is unavailable, so NewWatcher falls back to polling.
+func newInotifyBackend(recursive bool, events chan<- WatchEvent, errs chan<- error, done <-chan struct{}, wg *sync.WaitGroup) (watchBackend, error) {
+	return nil, errors.New("fileutil: inotify is not available on this platform")
+}
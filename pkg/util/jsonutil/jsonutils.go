@@ -10,6 +10,8 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+
+	"go-infrastructure/pkg/util/fileutil"
 )
 
 // Marshal takes an input Go data structure and returns its JSON encoding
@@ -46,13 +48,15 @@ func ReadJSONFromFile(filename string, v interface{}) error {
 	return Unmarshal(data, v)
 }
 
-// WriteJSONToFile takes a data structure and writes it as JSON to a file
-func WriteJSONToFile(filename string, v interface{}) error {
+// WriteJSONToFile takes a data structure and writes it as JSON to a file.
+// Pass fileutil.Atomic() to replace the file atomically instead of truncating it in place.
+func WriteJSONToFile(filename string, v interface{}, opts ...fileutil.WriteOption) error {
 	data, err := Marshal(v)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(filename, data, os.ModePerm)
+	opts = append([]fileutil.WriteOption{fileutil.WithPerm(os.ModePerm)}, opts...)
+	return fileutil.WriteBinaryFile(filename, data, opts...)
 }
 
 // GetJSONFromURL fetches a JSON document from the specified URL and decodes it into the provided variable.
@@ -88,6 +92,8 @@ func PrettyPrintToWriter(data []byte, writer io.Writer) error {
 }
 
 // FlattenJSON converts nested JSON object into a flat map with compound keys.
+// When two values flatten to the same key, as with {"a.b": 1, "a": {"b": 2}}, either one may be kept;
+// use FlattenMap to have such collisions reported.
 func FlattenJSON(data []byte) (map[string]interface{}, error) {
 	var original map[string]interface{}
 	err := json.Unmarshal(data, &original)
@@ -96,21 +102,38 @@ func FlattenJSON(data []byte) (map[string]interface{}, error) {
 	}
 
 	flatMap := make(map[string]interface{})
-	flatten("", original, flatMap)
+	flatten("", original, flatMap, false)
 	return flatMap, nil
 }
 
-// Helper function to recursively flatten the JSON.
-func flatten(currentPath string, value interface{}, flatMap map[string]interface{}) {
+// FlattenMap converts a decoded JSON object into a flat map with compound keys, as FlattenJSON does:
+// nested objects are joined with dots and every other value, arrays included, is kept as a leaf.
+// Unlike FlattenJSON, it fails if two values flatten to the same key.
+func FlattenMap(obj map[string]interface{}) (map[string]interface{}, error) {
+	flatMap := make(map[string]interface{})
+	if err := flatten("", obj, flatMap, true); err != nil {
+		return nil, err
+	}
+	return flatMap, nil
+}
+
+// Helper function to recursively flatten the JSON. With strict set, a key seen twice is an error.
+func flatten(currentPath string, value interface{}, flatMap map[string]interface{}, strict bool) error {
 	if castedMap, ok := value.(map[string]interface{}); ok {
 		for k, v := range castedMap {
 			newPath := fmt.Sprintf("%s.%s", currentPath, k)
-			flatten(newPath, v, flatMap)
+			if err := flatten(newPath, v, flatMap, strict); err != nil {
+				return err
+			}
 		}
-	} else {
-		trimmedPath := strings.TrimPrefix(currentPath, ".")
-		flatMap[trimmedPath] = value
+		return nil
+	}
+	trimmedPath := strings.TrimPrefix(currentPath, ".")
+	if _, ok := flatMap[trimmedPath]; ok && strict {
+		return fmt.Errorf("duplicate key after flattening: %s", trimmedPath)
 	}
+	flatMap[trimmedPath] = value
+	return nil
 }
 
 // MergeJSON merges two JSON objects, with values from the second object overriding those in the first if they conflict.
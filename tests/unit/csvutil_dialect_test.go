@@ -0,0 +1,56 @@
+package unit
+
+import (
+	"testing"
+
+	"go-infrastructure/pkg/util/csvutil"
+)
+
+func TestSniff(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		sample    string
+		delimiter rune
+		comment   rune
+		header    bool
+	}{
+		{"comma with header", "name,age\nann,30\nbob,41\n", ',', 0, true},
+		{"semicolon without header", "1;2;3\n4;5;6\n", ';', 0, false},
+		{"tab", "a\tb\n1\t2\n", '\t', 0, true},
+		{"quoted delimiters", "\"a;b\",c\n\"d;e\",f\n", ',', 0, false},
+		{"leading comments", "# exported 2024-01-02\n# by tool\nname,age\nann,30\n", ',', '#', true},
+		{"comment among rows", "name,age\nann,30\n# skipped below\nbob,41\n", ',', '#', true},
+		{"colour column", "color,name\n#ff0000,red\n#00ff00,green\n", ',', 0, true},
+		{"colour column first", "#ff0000,red\n#00ff00,green\nblue,#0000ff\n", ',', 0, false},
+		{"single column with leading comment", "# ids\n1\n2\n", ',', '#', false},
+		{"only hash lines", "#a\n#b\n", ',', 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := csvutil.Sniff([]byte(tc.sample), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := result.Dialect
+			if d.Delimiter != tc.delimiter || d.Comment != tc.comment || result.HasHeader != tc.header {
+				t.Errorf("got delimiter %q, comment %q, header %v; want %q, %q, %v",
+					d.Delimiter, d.Comment, result.HasHeader, tc.delimiter, tc.comment, tc.header)
+			}
+		})
+	}
+}
+
+func TestSniffPartialAndEncodedSamples(t *testing.T) {
+	result, err := csvutil.Sniff([]byte("a;b\n1;2\n3;4\n5,6,7,8"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dialect.Delimiter != ';' {
+		t.Errorf("got delimiter %q", result.Dialect.Delimiter)
+	}
+	if _, err := csvutil.Sniff([]byte("\n\n"), true); err == nil {
+		t.Error("empty sample accepted")
+	}
+	if result, err := csvutil.Sniff([]byte("\xff\xfea\x00,\x00b\x00\n\x001\x00,\x002\x00\n\x00"), true); err != nil || result.Dialect.Encoding != csvutil.EncodingUTF16LE {
+		t.Errorf("UTF-16: got %+v, %v", result, err)
+	}
+}
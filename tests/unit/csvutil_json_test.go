@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/csvutil"
+	"go-infrastructure/pkg/util/jsonutil"
+)
+
+func csvToJSON(t *testing.T, input string, opts csvutil.JSONOptions) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := csvutil.CsvToJSON(&out, csvutil.NewReader(strings.NewReader(input), true), opts); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func jsonToCsv(input string, opts csvutil.JSONOptions) (string, error) {
+	var out bytes.Buffer
+	err := csvutil.JSONToCsv(csvutil.NewWriter(&out), strings.NewReader(input), opts)
+	return out.String(), err
+}
+
+func TestCsvJSONRoundTrip(t *testing.T) {
+	input := "id,name,tags,user.active,user.code\n" +
+		"1,<a&b>,\"[\"\"x\"\",\"\"<y>\"\"]\",true,007\n" +
+		"2,\"multi\nline\",,false,\n"
+	for _, opts := range []csvutil.JSONOptions{{}, {Lines: true}, {KeepStrings: true}} {
+		js := csvToJSON(t, input, opts)
+		got, err := jsonToCsv(js, csvutil.JSONOptions{})
+		if err != nil {
+			t.Fatalf("%+v: %v\n%s", opts, err, js)
+		}
+		if got != input {
+			t.Errorf("%+v: got\n%s\nwant\n%s\nvia\n%s", opts, got, input, js)
+		}
+	}
+}
+
+func TestCsvToJSONTypes(t *testing.T) {
+	got := csvToJSON(t, "a.b,a.c,d\n1.5,<&>,\n", csvutil.JSONOptions{Lines: true})
+	if want := `{"a":{"b":1.5,"c":"<&>"},"d":null}` + "\n"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestJSONToCsvArraysAreNotHTMLEscaped(t *testing.T) {
+	got, err := jsonToCsv(`[{"a":["<b>","&"]}]`, csvutil.JSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\n\"[\"\"<b>\"\",\"\"&\"\"]\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestJSONToCsvRejectsTrailingData(t *testing.T) {
+	if _, err := jsonToCsv(`[{"a":1}] {"a":2}`, csvutil.JSONOptions{}); err == nil {
+		t.Error("trailing object accepted")
+	}
+	if _, err := jsonToCsv(`[{"a":1}] ]`, csvutil.JSONOptions{}); err == nil {
+		t.Error("trailing bracket accepted")
+	}
+	if got, err := jsonToCsv("[{\"a\":1}]\n\n", csvutil.JSONOptions{}); err != nil || got != "a\n1\n" {
+		t.Errorf("trailing whitespace: got %q, %v", got, err)
+	}
+}
+
+func TestFlattenKeyCollision(t *testing.T) {
+	input := `{"a.b":1,"a":{"b":2}}`
+	if _, err := jsonToCsv(input, csvutil.JSONOptions{}); err == nil {
+		t.Error("JSONToCsv accepted colliding keys")
+	}
+	if _, err := jsonutil.FlattenMap(map[string]interface{}{"a.b": 1.0, "a": map[string]interface{}{"b": 2.0}}); err == nil {
+		t.Error("FlattenMap accepted colliding keys")
+	}
+	// FlattenJSON keeps one of the colliding values, as it always has.
+	if flat, err := jsonutil.FlattenJSON([]byte(input)); err != nil || len(flat) != 1 {
+		t.Errorf("FlattenJSON: got %v, %v", flat, err)
+	}
+	flat, err := jsonutil.FlattenJSON([]byte(`{"a":{"b":{"c":1}},"d":[1]}`))
+	if err != nil || len(flat) != 2 || flat["a.b.c"] != 1.0 {
+		t.Errorf("got %v, %v", flat, err)
+	}
+}
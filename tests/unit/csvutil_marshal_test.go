@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/csvutil"
+)
+
+type marshalBase struct {
+	ID      int    `csv:"id"`
+	Comment string // hidden by the shallower Comment of the outer types
+}
+
+type marshalAudit struct {
+	Created time.Time `csv:"created" layout:"2006-01-02"`
+	Comment string
+}
+
+type marshalRow struct {
+	*marshalAudit // ignored: a pointer to an unexported type cannot be allocated
+	marshalBase
+	Name    string   `csv:"name"`
+	Score   float64  `csv:"score"`
+	Ratio   *float32 `csv:"ratio"`
+	Elapsed time.Duration
+	Note    string `csv:"note,omitempty"`
+	Comment string
+	Skipped string `csv:"-"`
+}
+
+// Audit is exported so that an embedded pointer to it can be allocated while unmarshalling.
+type Audit struct {
+	Created time.Time `csv:"created" layout:"2006-01-02"`
+}
+
+type marshalPointerRow struct {
+	*Audit
+	Name string `csv:"name"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	ratio := float32(0.1)
+	rows := []marshalRow{
+		{marshalBase: marshalBase{ID: 1}, Name: "a, b", Score: 0.1, Ratio: &ratio, Elapsed: 90 * time.Second, Comment: "c", Skipped: "x"},
+		{marshalBase: marshalBase{ID: 2}, Name: "big", Score: 1e21, Note: "n"},
+	}
+	var buf bytes.Buffer
+	if err := csvutil.Marshal(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,name,score,ratio,Elapsed,note,Comment\n" +
+		"1,\"a, b\",0.1,0.1,1m30s,,c\n" +
+		"2,big,1e+21,,0s,n,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	back, err := csvutil.Unmarshal[marshalRow](strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 2 || back[0].ID != 1 || back[0].Name != "a, b" || back[0].Score != 0.1 || *back[0].Ratio != 0.1 ||
+		back[0].Elapsed != 90*time.Second || back[0].Comment != "c" || back[1].Score != 1e21 || back[1].Ratio != nil || back[1].Note != "n" {
+		t.Errorf("got %+v", back)
+	}
+}
+
+func TestMarshalEmbeddedPointer(t *testing.T) {
+	rows := []*marshalPointerRow{
+		{Audit: &Audit{Created: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}, Name: "with"},
+		{Name: "without"},
+	}
+	var buf bytes.Buffer
+	if err := csvutil.Marshal(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "created,name\n2024-01-02,with\n,without\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	back, err := csvutil.Unmarshal[*marshalPointerRow](strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 2 || back[0].Audit == nil || !back[0].Created.Equal(rows[0].Created) || back[1].Name != "without" {
+		t.Errorf("got %+v", back)
+	}
+}
+
+type ambiguousA struct{ Value, OnlyA string }
+type ambiguousB struct{ Value string }
+type ambiguousTagged struct {
+	Value string `csv:"Value"`
+}
+
+func TestMarshalDropsAmbiguousFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := csvutil.Marshal(&buf, []struct {
+		ambiguousA
+		ambiguousB
+	}{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if header, _, _ := strings.Cut(buf.String(), "\n"); header != "OnlyA" {
+		t.Errorf("untagged tie: got header %q", header)
+	}
+
+	buf.Reset()
+	if err := csvutil.Marshal(&buf, []struct {
+		ambiguousB
+		ambiguousTagged
+	}{{ambiguousB{"untagged"}, ambiguousTagged{"tagged"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "Value\ntagged\n" {
+		t.Errorf("tagged field must win a tie: got %q", got)
+	}
+}
+
+func TestUnmarshalFieldError(t *testing.T) {
+	_, err := csvutil.Unmarshal[marshalRow](strings.NewReader("id,name\n1,a\nx,b\n"))
+	var fieldErr *csvutil.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Line != 3 || fieldErr.Row != 1 || fieldErr.Column != "id" || fieldErr.Value != "x" {
+		t.Fatalf("got %v", err)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %v, want it to wrap strconv.ErrSyntax", err)
+	}
+}
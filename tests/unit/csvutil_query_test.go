@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"testing"
+
+	"go-infrastructure/pkg/util/csvutil"
+)
+
+func sortTable(t *testing.T, records [][]string, opts csvutil.SortOptions, keys ...csvutil.SortKey) [][]string {
+	t.Helper()
+	table, err := csvutil.TableFromRecords(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted, err := table.Sort(opts, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := sorted.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestSortSpillsEmptySingleFieldRows(t *testing.T) {
+	records := [][]string{{"v"}, {"b"}, {""}, {"a"}, {""}, {"c"}, {""}}
+	dir := t.TempDir()
+	got := sortTable(t, records, csvutil.SortOptions{MaxMemory: 1, TempDir: dir}, csvutil.SortKey{Column: "v"})
+	want := [][]string{{"v"}, {""}, {""}, {""}, {"a"}, {"b"}, {"c"}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spill files left behind: %v", entries)
+	}
+}
+
+func TestSortSpilledMatchesInMemory(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	records := [][]string{{"n", "seq", "note"}}
+	for i := 0; i < 500; i++ {
+		note := fmt.Sprintf("line\n%d, \"quoted\"", r.IntN(3))
+		if r.IntN(4) == 0 {
+			note = ""
+		}
+		records = append(records, []string{fmt.Sprint(r.IntN(50) - 25), fmt.Sprint(i), note})
+	}
+	keys := []csvutil.SortKey{{Column: "n", Numeric: true, Desc: true}, {Column: "note"}}
+	inMemory := sortTable(t, records, csvutil.SortOptions{}, keys...)
+	spilled := sortTable(t, records, csvutil.SortOptions{MaxMemory: 2000, TempDir: t.TempDir()}, keys...)
+	if len(inMemory) != 501 || !slices.EqualFunc(inMemory, spilled, slices.Equal) {
+		t.Errorf("spilled sort differs from the in-memory one: %d vs %d records", len(spilled), len(inMemory))
+	}
+}
@@ -0,0 +1,45 @@
+package unit
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/csvutil"
+)
+
+func TestValidateReportsMalformedRows(t *testing.T) {
+	schema := &csvutil.Schema{Columns: []csvutil.Column{
+		{Name: "id", Type: csvutil.TypeInt, Required: true},
+		{Name: "name"},
+	}}
+	input := "id,name\n" +
+		"x,a\n" + // bad int
+		"1,b,extra\n" + // wrong field count
+		"2,\"c\n" // unterminated quote
+	err := schema.Validate(strings.NewReader(input))
+	var verr *csvutil.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	if len(verr.Errors) != 3 {
+		t.Fatalf("got %d violations: %v", len(verr.Errors), verr.Errors)
+	}
+	if !errors.Is(verr.Errors[1], csv.ErrFieldCount) || verr.Errors[1].Line != 3 {
+		t.Errorf("got %v", verr.Errors[1])
+	}
+	if !errors.Is(verr.Errors[2], csv.ErrQuote) || verr.Errors[2].Line != 4 {
+		t.Errorf("got %v", verr.Errors[2])
+	}
+	if got := verr.Errors[1].Error(); got != "csvutil: line 3: wrong number of fields" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestValidateValidInput(t *testing.T) {
+	schema := &csvutil.Schema{Columns: []csvutil.Column{{Name: "n", Type: csvutil.TypeFloat, Default: "0"}}}
+	if err := schema.Validate(strings.NewReader("n\n1.5\n\n2\n")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/csvutil"
+)
+
+func TestStreamReader(t *testing.T) {
+	r := csvutil.NewReader(strings.NewReader("name,age\nann,30\n\"bob\nby\",41\ncarl\n"), true)
+	header, err := r.Header()
+	if err != nil || !slices.Equal(header, []string{"name", "age"}) {
+		t.Fatalf("Header: got %q, %v", header, err)
+	}
+	m, err := r.NextMap()
+	if err != nil || m["name"] != "ann" || m["age"] != "30" {
+		t.Errorf("NextMap: got %v, %v", m, err)
+	}
+	record, err := r.Next()
+	if err != nil || !slices.Equal(record, []string{"bob\nby", "41"}) || r.Line() != 3 {
+		t.Errorf("Next: got %q, %v at line %d", record, err, r.Line())
+	}
+	r.CSV().FieldsPerRecord = -1
+	if _, err := r.NextMap(); !errors.Is(err, csv.ErrFieldCount) {
+		t.Errorf("short record: got %v, want ErrFieldCount", err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+	if r.Rows() != 3 {
+		t.Errorf("Rows: got %d", r.Rows())
+	}
+
+	if _, err := csvutil.NewReader(strings.NewReader("a,b\n"), false).Header(); !errors.Is(err, csvutil.ErrNoHeader) {
+		t.Errorf("no header: got %v", err)
+	}
+	if _, err := csvutil.NewReader(strings.NewReader(""), true).Next(); err == nil || err == io.EOF {
+		t.Errorf("empty input with a header expected: got %v", err)
+	}
+}
+
+func TestStreamReaderAll(t *testing.T) {
+	r := csvutil.NewReader(strings.NewReader("a\nb\n\"c\n"), false)
+	var got []string
+	var gotErr error
+	for record, err := range r.All() {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		got = append(got, record[0])
+	}
+	if !slices.Equal(got, []string{"a", "b"}) || gotErr == nil {
+		t.Errorf("got %q, %v; want two records and the parse error", got, gotErr)
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := csvutil.NewWriter(&buf)
+	if err := w.WriteMap(map[string]string{"a": "1"}); !errors.Is(err, csvutil.ErrNoHeader) {
+		t.Errorf("WriteMap without header: got %v", err)
+	}
+	if err := w.WriteHeader([]string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader([]string{"a", "b"}); err == nil {
+		t.Error("second header accepted")
+	}
+	if err := w.WriteMap(map[string]string{"b": "2", "extra": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]string{"x,y", "z"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q before Flush", buf.String())
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "a,b\n,2\n\"x,y\",z\n"; got != want || w.Rows() != 2 {
+		t.Errorf("got %q (%d rows), want %q", got, w.Rows(), want)
+	}
+}
+
+func TestTransformKeepsOrder(t *testing.T) {
+	var in strings.Builder
+	in.WriteString("n\n")
+	for i := range 500 {
+		fmt.Fprintf(&in, "%d\n", i)
+	}
+	var out bytes.Buffer
+	w := csvutil.NewWriter(&out)
+	err := csvutil.Transform(context.Background(), csvutil.NewReader(strings.NewReader(in.String()), true), w, csvutil.TransformOptions{Workers: 8},
+		func(row int, record []string) ([]string, error) {
+			n, err := strconv.Atoi(record[0])
+			if err != nil || n != row {
+				return nil, fmt.Errorf("row %d: got %q", row, record)
+			}
+			time.Sleep(time.Duration(n%7) * 10 * time.Microsecond) // finish out of order
+			if n%2 == 1 {
+				return nil, nil // dropped
+			}
+			return []string{record[0], strconv.Itoa(n * n)}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 251 || lines[0] != "n" {
+		t.Fatalf("got %d lines starting with %q", len(lines), lines[0])
+	}
+	for i, line := range lines[1:] {
+		if want := fmt.Sprintf("%d,%d", 2*i, 4*i*i); line != want {
+			t.Fatalf("line %d: got %q, want %q", i+1, line, want)
+		}
+	}
+}
+
+func TestTransformStopsOnError(t *testing.T) {
+	input := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	boom := errors.New("boom")
+	var out bytes.Buffer
+	w := csvutil.NewWriter(&out)
+	err := csvutil.Transform(context.Background(), csvutil.NewReader(strings.NewReader(input), false), w,
+		csvutil.TransformOptions{Workers: 2, Header: []string{"value"}},
+		func(row int, record []string) ([]string, error) {
+			if row == 3 {
+				return nil, boom
+			}
+			return record, nil
+		})
+	if !errors.Is(err, boom) {
+		t.Fatalf("got %v, want boom", err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "value\n") || strings.Contains(got, "4") {
+		t.Errorf("got %q; want the header and at most the rows before the failing one", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = csvutil.Transform(ctx, csvutil.NewReader(strings.NewReader(input), false), csvutil.NewWriter(io.Discard), csvutil.TransformOptions{},
+		func(row int, record []string) ([]string, error) { return record, nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// archiveNames lists the entry names of a zip or tar archive.
+func archiveNames(t *testing.T, format fileutil.ArchiveFormat, data []byte) []string {
+	t.Helper()
+	var names []string
+	collect := func(e *fileutil.ArchiveEntry) error {
+		names = append(names, e.Name)
+		return nil
+	}
+	var err error
+	if format == fileutil.FormatZip {
+		err = fileutil.ReadZipEntries(bytes.NewReader(data), int64(len(data)), collect, fileutil.StreamOptions{})
+	} else {
+		err = fileutil.ReadTarEntries(bytes.NewReader(data), collect, fileutil.StreamOptions{})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestWriteArchiveDirectoryNamesAndEntries(t *testing.T) {
+	for _, format := range []fileutil.ArchiveFormat{fileutil.FormatZip, fileutil.FormatTar} {
+		dir := &fileutil.ArchiveEntry{Name: "dir/", Mode: os.ModeDir}
+		var buf bytes.Buffer
+		if err := fileutil.WriteArchive(&buf, format, entries(dir), fileutil.StreamOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := archiveNames(t, format, buf.Bytes()); !slices.Equal(got, []string{"dir/"}) {
+			t.Errorf("%s: got %q", format, got)
+		}
+		if dir.Mode != os.ModeDir || !dir.ModTime.IsZero() || dir.Body != nil {
+			t.Errorf("%s: entry modified to %+v", format, dir)
+		}
+	}
+}
+
+func TestArchiveDirFilters(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.tmp\nbuild/\n!keep.tmp\n",
+		"a.txt":            "a",
+		"x.tmp":            "x",
+		"keep.tmp":         "k",
+		"build/out.bin":    "o",
+		"src/b.txt":        "b",
+		"src/c.log":        "c",
+		"src/deep/d.txt":   "d",
+		"vendor/e.txt":     "e",
+		"vendor/sub/f.txt": "f",
+	})
+	out := filepath.Join(root, "out.zip") // inside root, so it must be skipped
+	opts := fileutil.ArchiveOptions{IgnoreFile: ".gitignore", Exclude: []string{"vendor", "*.log"}}
+	if err := fileutil.ArchiveDir(root, out, opts); err != nil {
+		t.Fatal(err)
+	}
+	got := archiveNames(t, fileutil.FormatZip, readFile(t, out))
+	want := []string{".gitignore", "a.txt", "keep.tmp", "src/", "src/b.txt", "src/deep/", "src/deep/d.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	opts = fileutil.ArchiveOptions{Format: fileutil.FormatTar, Include: []string{"src/**/*.txt"}}
+	if err := fileutil.ArchiveDirTo(&buf, root, opts); err != nil {
+		t.Fatal(err)
+	}
+	got = archiveNames(t, fileutil.FormatTar, buf.Bytes())
+	want = []string{"src/", "src/b.txt", "src/deep/", "src/deep/d.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("include: got %q, want %q", got, want)
+	}
+}
+
+func TestArchiveDirReproducible(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.txt": "a", "dir/b.txt": "b"})
+	opts := fileutil.ArchiveOptions{Format: fileutil.FormatTarGz, ModTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var first, second bytes.Buffer
+	if err := fileutil.ArchiveDirTo(&first, root, opts); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(root, "a.txt"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.ArchiveDirTo(&second, root, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("archives of the same tree differ")
+	}
+}
+
+func TestIgnorePatterns(t *testing.T) {
+	p := fileutil.NewIgnorePatterns([]string{"# comment", "*.log", "!important.log", "/root-only", "logs/", `\#hash`, "a/**/z"})
+	for _, tc := range []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"x.log", false, true},
+		{"deep/x.log", false, true},
+		{"important.log", false, false},
+		{"root-only", false, true},
+		{"sub/root-only", false, false},
+		{"logs", true, true},
+		{"logs", false, false},
+		{"#hash", false, true},
+		{"a/z", false, true},
+		{"a/b/c/z", false, true},
+		{"b/z", false, false},
+	} {
+		if got := p.Match(tc.rel, tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.rel, tc.isDir, got, tc.want)
+		}
+	}
+}
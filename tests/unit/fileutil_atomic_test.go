@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+	"go-infrastructure/pkg/util/jsonutil"
+)
+
+// openFileMode returns the mode os.OpenFile gives a new file created with perm, i.e. after the umask.
+func openFileMode(t *testing.T, perm os.FileMode) os.FileMode {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "reference")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return fileMode(t, path)
+}
+
+func fileMode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestWriteFileAtomicNewFileRespectsUmask(t *testing.T) {
+	for _, perm := range []os.FileMode{0600, 0644, 0666, 0777} {
+		path := filepath.Join(t.TempDir(), "file")
+		if err := fileutil.WriteFileAtomic(path, []byte("data"), perm); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := fileMode(t, path), openFileMode(t, perm); got != want {
+			t.Errorf("perm %v: got mode %v, want %v", perm, got, want)
+		}
+	}
+}
+
+func TestWriteFileAtomicKeepsExistingMode(t *testing.T) {
+	path := writeTemp(t, []byte("old"))
+	if err := os.Chmod(path, 0604); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.WriteFileAtomic(path, []byte("new"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileMode(t, path); got != 0604 {
+		t.Errorf("got mode %v, want 0604", got)
+	}
+	if got := string(readFile(t, path)); got != "new" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriteJSONToFileAtomicMatchesInPlace(t *testing.T) {
+	dir := t.TempDir()
+	inPlace := filepath.Join(dir, "in-place.json")
+	atomic := filepath.Join(dir, "atomic.json")
+	if err := jsonutil.WriteJSONToFile(inPlace, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonutil.WriteJSONToFile(atomic, map[string]int{"a": 1}, fileutil.Atomic()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fileMode(t, atomic), fileMode(t, inPlace); got != want {
+		t.Errorf("atomic write gave mode %v, in-place %v", got, want)
+	}
+	if got := string(readFile(t, atomic)); got != `{"a":1}` {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAtomicFileAbortLeavesTarget(t *testing.T) {
+	path := writeTemp(t, []byte("old"))
+	f, err := fileutil.CreateAtomic(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("partial")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, path)); got != "old" {
+		t.Errorf("got %q", got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
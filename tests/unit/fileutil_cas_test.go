@@ -0,0 +1,167 @@
+package unit
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+	"go-infrastructure/pkg/util/fileutil/cas"
+)
+
+func TestCASPutGet(t *testing.T) {
+	s, err := cas.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeTemp(t, []byte("hello"))
+	digest, err := s.PutFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, err := fileutil.CalculateFileSHA256(path); err != nil || digest != want {
+		t.Errorf("got digest %s, want %s (%v)", digest, want, err)
+	}
+	again, err := s.Put(strings.NewReader("hello"))
+	if err != nil || again != digest {
+		t.Errorf("second Put: got %s, %v", again, err)
+	}
+
+	rc, err := s.Get(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Get: got %q, %v", data, err)
+	}
+	if ok, err := s.Has(digest); !ok || err != nil {
+		t.Errorf("Has: got %v, %v", ok, err)
+	}
+	if size, err := s.Size(digest); size != 5 || err != nil {
+		t.Errorf("Size: got %d, %v", size, err)
+	}
+	var walked []string
+	if err := s.Walk(func(d string, size int64) error {
+		walked = append(walked, d)
+		return nil
+	}); err != nil || !slices.Equal(walked, []string{digest}) {
+		t.Errorf("Walk: got %q, %v; the duplicate Put must not store a second copy", walked, err)
+	}
+
+	if err := s.Delete(digest); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Has(digest); ok || err != nil {
+		t.Errorf("Has after Delete: got %v, %v", ok, err)
+	}
+	if _, err := s.Get(digest); !errors.Is(err, cas.ErrNotFound) {
+		t.Errorf("Get after Delete: got %v", err)
+	}
+	if err := s.Delete(digest); !errors.Is(err, cas.ErrNotFound) {
+		t.Errorf("Delete after Delete: got %v", err)
+	}
+}
+
+func TestCASRejectsInvalidDigests(t *testing.T) {
+	s, err := cas.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := strings.Repeat("ab", 32)
+	for _, digest := range []string{"", "../../etc/passwd", strings.ToUpper(valid), valid[1:], valid + "0"} {
+		if _, err := s.Get(digest); !errors.Is(err, cas.ErrInvalidDigest) {
+			t.Errorf("Get(%q): got %v", digest, err)
+		}
+	}
+	if !cas.ValidDigest(valid) {
+		t.Errorf("ValidDigest(%q) = false", valid)
+	}
+}
+
+func TestCASDetectsCorruption(t *testing.T) {
+	s, err := cas.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := s.Put(strings.NewReader("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(digest); err != nil {
+		t.Fatalf("Verify of an intact blob: %v", err)
+	}
+	path, _ := s.Path(digest)
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(digest); !errors.Is(err, cas.ErrCorrupt) {
+		t.Errorf("Verify: got %v, want ErrCorrupt", err)
+	}
+}
+
+func TestCASGC(t *testing.T) {
+	dir := t.TempDir()
+	s, err := cas.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, err := s.Put(strings.NewReader("live"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, err := s.Put(strings.NewReader("dead!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent, err := s.Put(strings.NewReader("recent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, digest := range []string{live, dead} {
+		path, _ := s.Path(digest)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	staleTmp := filepath.Join(dir, "tmp", "put-stale")
+	if err := os.WriteFile(staleTmp, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(staleTmp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	isLive := func(digest string) bool { return digest == live }
+	opts := cas.GCOptions{GracePeriod: time.Hour, DryRun: true}
+
+	result, err := s.GC(isLive, opts)
+	if err != nil || !slices.Equal(result.Removed, []string{dead}) || result.Freed != 5 {
+		t.Fatalf("dry run: got %+v, %v", result, err)
+	}
+	if ok, _ := s.Has(dead); !ok {
+		t.Error("dry run removed a blob")
+	}
+
+	opts.DryRun = false
+	if result, err = s.GC(isLive, opts); err != nil || !slices.Equal(result.Removed, []string{dead}) {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+	for digest, want := range map[string]bool{live: true, dead: false, recent: true} {
+		if ok, err := s.Has(digest); ok != want || err != nil {
+			t.Errorf("Has(%s): got %v, %v, want %v", digest, ok, err, want)
+		}
+	}
+	if _, err := os.Stat(staleTmp); !os.IsNotExist(err) {
+		t.Errorf("stale temporary file: got %v, want it removed", err)
+	}
+}
@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func randomLines(r *rand.Rand, n int) []string {
+	lines := make([]string, r.IntN(n+1))
+	for i := range lines {
+		lines[i] = string(rune('a' + r.IntN(4)))
+	}
+	return lines
+}
+
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(r, 20), randomLines(r, 20)
+		context := r.IntN(4)
+		patch := fileutil.UnifiedDiff("a", "b", a, b, context)
+		if (patch == "") != slices.Equal(a, b) {
+			t.Fatalf("a=%q b=%q: empty patch %v for equal=%v", a, b, patch == "", slices.Equal(a, b))
+		}
+		got, err := fileutil.ApplyUnifiedDiff(a, patch)
+		if err != nil {
+			t.Fatalf("a=%q b=%q context=%d: %v\n%s", a, b, context, err, patch)
+		}
+		if !slices.Equal(got, b) {
+			t.Fatalf("a=%q b=%q context=%d: got %q\n%s", a, b, context, got, patch)
+		}
+	}
+}
+
+func TestUnifiedDiffFormat(t *testing.T) {
+	a := []string{"one", "two", "three", "four"}
+	b := []string{"one", "2", "three", "four", "five"}
+	want := `--- old
++++ new
+@@ -1,4 +1,5 @@
+ one
+-two
++2
+ three
+ four
++five
+`
+	if got := fileutil.UnifiedDiff("old", "new", a, b, 3); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	hunks, err := fileutil.ParseUnifiedDiff(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 || hunks[0].Header() != "@@ -1,4 +1,5 @@" {
+		t.Errorf("got %+v", hunks)
+	}
+}
+
+func TestApplyUnifiedDiffConflict(t *testing.T) {
+	a := []string{"one", "two", "three"}
+	patch := fileutil.UnifiedDiff("a", "b", a, []string{"one", "TWO", "three"}, 1)
+	_, err := fileutil.ApplyUnifiedDiff([]string{"one", "deux", "three"}, patch)
+	if !errors.Is(err, fileutil.ErrPatchConflict) {
+		t.Errorf("got %v, want ErrPatchConflict", err)
+	}
+}
+
+func TestFilesEqual(t *testing.T) {
+	content := strings.Repeat("0123456789", 20000)
+	a := writeTemp(t, []byte(content))
+	b := writeTemp(t, []byte(content))
+	c := writeTemp(t, []byte(content[:len(content)-1]+"x"))
+	if eq, err := fileutil.FilesEqual(a, b); err != nil || !eq {
+		t.Errorf("equal files: %v, %v", eq, err)
+	}
+	if eq, err := fileutil.FilesEqual(a, c); err != nil || eq {
+		t.Errorf("different files: %v, %v", eq, err)
+	}
+}
+
+func TestParseUnifiedDiffMultipleFiles(t *testing.T) {
+	// The removed SQL comment "-- note" looks like a file header; only the hunk counts tell them apart.
+	patch := `diff --git a/one.sql b/one.sql
+--- a/one.sql
++++ b/one.sql
+@@ -1,2 +1,1 @@
+--- note
+ select 1;
+diff --git a/two.txt b/two.txt
+--- a/two.txt
++++ b/two.txt
+@@ -1 +1,2 @@
+ x
++++ y
+\ No newline at end of file
+`
+	hunks, err := fileutil.ParseUnifiedDiff(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2: %+v", len(hunks), hunks)
+	}
+	if want := []fileutil.DiffLine{{Kind: '-', Text: "-- note"}, {Kind: ' ', Text: "select 1;"}}; !slices.Equal(hunks[0].Lines, want) {
+		t.Errorf("first hunk: got %q, want %q", hunks[0].Lines, want)
+	}
+	if want := []fileutil.DiffLine{{Kind: ' ', Text: "x"}, {Kind: '+', Text: "++ y"}}; !slices.Equal(hunks[1].Lines, want) {
+		t.Errorf("second hunk: got %q, want %q", hunks[1].Lines, want)
+	}
+
+	for name, bad := range map[string]string{
+		"short":    "@@ -1,3 +1,3 @@\n a\n-b\n+c\n",
+		"too long": "@@ -1,2 +1,2 @@\n a\n-b\n+c\n d\n",
+		"garbage":  "@@ -1,2 +1,2 @@\n a\nb\n",
+	} {
+		if _, err := fileutil.ParseUnifiedDiff(bad); err == nil {
+			t.Errorf("%s hunk: no error", name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+type zipEntry struct {
+	name string
+	body string
+	mode os.FileMode
+}
+
+func writeZip(t *testing.T, entries ...zipEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeTemp(t, buf.Bytes())
+}
+
+func wantRule(t *testing.T, err error, rule fileutil.ExtractRule) {
+	t.Helper()
+	var xerr *fileutil.ExtractError
+	if !errors.As(err, &xerr) {
+		t.Fatalf("got error %v, want rule %q", err, rule)
+	}
+	if xerr.Rule != rule {
+		t.Errorf("got rule %q, want %q", xerr.Rule, rule)
+	}
+}
+
+func TestUnzipExtractsFiles(t *testing.T) {
+	src := writeZip(t, zipEntry{name: "dir/", mode: os.ModeDir | 0755}, zipEntry{name: "dir/a.txt", body: "hello"})
+	dest := t.TempDir()
+	files, err := fileutil.Unzip(src, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("got %v, want 2 entries", files)
+	}
+	if got := string(readFile(t, filepath.Join(dest, "dir", "a.txt"))); got != "hello" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUnzipRejectsZipSlip(t *testing.T) {
+	tests := []struct {
+		name string
+		rule fileutil.ExtractRule
+	}{
+		{"../evil.txt", fileutil.RulePathTraversal},
+		{"a/../../evil.txt", fileutil.RulePathTraversal},
+		{`..\evil.txt`, fileutil.RulePathTraversal},
+		{"/tmp/evil.txt", fileutil.RuleAbsolutePath},
+		{`C:\evil.txt`, fileutil.RuleAbsolutePath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			_, err := fileutil.Unzip(writeZip(t, zipEntry{name: tt.name, body: "x"}), dest)
+			wantRule(t, err, tt.rule)
+			if _, err := os.Stat(filepath.Join(parent, "evil.txt")); !os.IsNotExist(err) {
+				t.Error("file written outside the destination")
+			}
+		})
+	}
+}
+
+func TestUnzipRejectsSymlinks(t *testing.T) {
+	src := writeZip(t, zipEntry{name: "link", body: "/etc/passwd", mode: os.ModeSymlink | 0777})
+	_, err := fileutil.Unzip(src, t.TempDir())
+	wantRule(t, err, fileutil.RuleSymlinkForbidden)
+
+	limits := fileutil.DefaultExtractLimits()
+	limits.AllowSymlinks = true
+	_, err = fileutil.UnzipWithLimits(src, t.TempDir(), limits)
+	wantRule(t, err, fileutil.RuleSymlinkEscape)
+}
+
+func TestUnzipLimits(t *testing.T) {
+	t.Run("entries", func(t *testing.T) {
+		limits := fileutil.DefaultExtractLimits()
+		limits.MaxEntries = 2
+		src := writeZip(t, zipEntry{name: "a", body: "1"}, zipEntry{name: "b", body: "2"}, zipEntry{name: "c", body: "3"})
+		_, err := fileutil.UnzipWithLimits(src, t.TempDir(), limits)
+		wantRule(t, err, fileutil.RuleEntryCount)
+	})
+	t.Run("total size", func(t *testing.T) {
+		limits := fileutil.DefaultExtractLimits()
+		limits.MaxTotalSize = 10
+		src := writeZip(t, zipEntry{name: "a", body: "123456"}, zipEntry{name: "b", body: "123456"})
+		_, err := fileutil.UnzipWithLimits(src, t.TempDir(), limits)
+		wantRule(t, err, fileutil.RuleTotalSize)
+	})
+	t.Run("compression ratio", func(t *testing.T) {
+		src := writeZip(t, zipEntry{name: "bomb", body: strings.Repeat("0", 8<<20)})
+		_, err := fileutil.Unzip(src, t.TempDir())
+		wantRule(t, err, fileutil.RuleCompressionRatio)
+	})
+}
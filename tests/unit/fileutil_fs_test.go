@@ -0,0 +1,229 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// writeTree creates the given files, slash-separated and relative to dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOSHelpersMatchFSVersions(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":       "one\ntwo\n",
+		"b.log":       "xyz",
+		"sub/c.txt":   "hello",
+		"sub/d/e.txt": "",
+	})
+
+	found, err := fileutil.FindFiles(root, "*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "c.txt"), filepath.Join(root, "sub", "d", "e.txt")}
+	if !slices.Equal(found, want) {
+		t.Errorf("FindFiles: got %q, want %q", found, want)
+	}
+	if found, err := fileutil.FindFiles(root, filepath.Base(root)); err != nil || !slices.Equal(found, []string{root}) {
+		t.Errorf("FindFiles matching the root: got %q, %v", found, err)
+	}
+	if _, err := fileutil.FindFiles(root, "["); err == nil {
+		t.Error("FindFiles accepted a malformed pattern")
+	}
+
+	size, err := fileutil.CalculateDirSize(root)
+	if err != nil || size != 16 {
+		t.Errorf("CalculateDirSize: got %d, %v", size, err)
+	}
+
+	lines, err := fileutil.ReadLines(filepath.Join(root, "a.txt"))
+	if err != nil || !slices.Equal(lines, []string{"one", "two"}) {
+		t.Errorf("ReadLines: got %q, %v", lines, err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := fileutil.CopyDirectory(root, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, filepath.Join(dst, "sub", "c.txt"))); got != "hello" {
+		t.Errorf("CopyDirectory: got %q", got)
+	}
+	if err := fileutil.CopyDirectory(root, dst); err == nil {
+		t.Error("CopyDirectory overwrote an existing destination")
+	}
+}
+
+func TestCopyDirectoryFollowsFileSymlinks(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"target.txt": "data"})
+	if err := os.Symlink("target.txt", filepath.Join(root, "link.txt")); err != nil {
+		t.Skip(err)
+	}
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := fileutil.CopyDirectory(root, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(filepath.Join(dst, "link.txt"))
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("got %v, %v", info, err)
+	}
+	if got := string(readFile(t, filepath.Join(dst, "link.txt"))); got != "data" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParallelWalkFS(t *testing.T) {
+	m := fileutil.NewMemFS()
+	if err := m.MkdirAll("a/b", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.WriteFileFS(m, "a/b/c.txt", []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	found, err := fileutil.FindFilesFS(m, ".", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".", "a", "a/b", "a/b/c.txt"}; !slices.Equal(found, want) {
+		t.Errorf("got %q, want %q", found, want)
+	}
+}
+
+func TestMemFSRename(t *testing.T) {
+	m := fileutil.NewMemFS()
+	for _, dir := range []string{"empty", "full", "moved"} {
+		if err := m.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"file", "other", "full/x", "moved/y"} {
+		if err := fileutil.WriteFileFS(m, name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct{ from, to string }{
+		{"file", "empty"}, // file over a directory
+		{"moved", "file"}, // directory over a file
+		{"moved", "full"}, // directory over a non-empty directory
+	} {
+		if err := m.Rename(tc.from, tc.to); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Rename(%q, %q): got %v, want ErrExist", tc.from, tc.to, err)
+		}
+	}
+	if _, err := m.Stat("empty"); err != nil {
+		t.Errorf("failed rename removed the target: %v", err)
+	}
+
+	if err := m.Rename("file", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(m, "other"); err != nil || string(data) != "file" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if err := m.Rename("moved", "empty"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(m, "empty/y"); err != nil || string(data) != "moved/y" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if err := m.Rename("other", "other"); err != nil {
+		t.Errorf("renaming onto itself: %v", err)
+	}
+}
+
+func TestWalkHelpersKeepWalkOrder(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a/b": "", "a-b": "", "a.c": "", "ab/c": ""})
+
+	var want []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			want = append(want, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fileutil.ListFilesRecursive(root); err != nil || !slices.Equal(got, want) {
+		t.Errorf("ListFilesRecursive: got %q, %v, want %q", got, err, want)
+	}
+	if got, err := fileutil.FindFiles(root, "?*"); err != nil || !slices.Equal(got, []string{
+		root, filepath.Join(root, "a"), want[0], want[1], want[2], filepath.Join(root, "ab"), want[3],
+	}) {
+		t.Errorf("FindFiles: got %q, %v", got, err)
+	}
+}
+
+func TestFindFilesFailsOnUnreadableDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"locked/x.txt": "", "y.txt": ""})
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("directory permissions are not enforced")
+	}
+	if got, err := fileutil.FindFiles(root, "*.txt"); err == nil || got != nil {
+		t.Errorf("got %q, %v; want nil and an error", got, err)
+	}
+	if got, err := fileutil.ListFilesRecursive(root); err == nil || !slices.Equal(got, []string{filepath.Join(root, "y.txt")}) {
+		t.Errorf("got %q, %v; want the readable files and an error", got, err)
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{"a.txt": "a", "big/1": strings.Repeat("x", 1<<20), "big/2": "", "big/3": "3", "d/e/f": "f"}
+	writeTree(t, root, files)
+
+	hashes, err := fileutil.HashTree(context.Background(), root, fileutil.WalkOptions{Workers: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, h := range hashes {
+		paths = append(paths, h.Path)
+		want, err := fileutil.CalculateFileSHA256(filepath.Join(root, filepath.FromSlash(h.Path)))
+		if err != nil || h.SHA256 != want || h.Size != int64(len(files[h.Path])) {
+			t.Errorf("%s: got %s (%d bytes), want %s (%v)", h.Path, h.SHA256, h.Size, want, err)
+		}
+	}
+	if want := []string{"a.txt", "big/1", "big/2", "big/3", "d/e/f"}; !slices.Equal(paths, want) {
+		t.Errorf("got %q, want %q", paths, want)
+	}
+
+	single, err := fileutil.HashTree(context.Background(), filepath.Join(root, "a.txt"), fileutil.WalkOptions{})
+	if err != nil || len(single) != 1 || single[0].Path != "a.txt" || single[0].SHA256 != hashes[0].SHA256 {
+		t.Errorf("file root: got %+v, %v", single, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fileutil.HashTree(ctx, root, fileutil.WalkOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: got %v", err)
+	}
+}
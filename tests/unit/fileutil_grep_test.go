@@ -0,0 +1,145 @@
+package unit
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// grepLines searches text with a new Grepper and collects the matches.
+func grepLines(t *testing.T, pattern, text string, opts fileutil.GrepOptions) []fileutil.GrepMatch {
+	t.Helper()
+	g, err := fileutil.NewGrepper(pattern, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matches []fileutil.GrepMatch
+	for m, err := range g.Reader("text", strings.NewReader(text)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func TestGrepPatterns(t *testing.T) {
+	text := "a.b\naxb\nA.B\n"
+	for _, tc := range []struct {
+		pattern string
+		opts    fileutil.GrepOptions
+		want    []int
+	}{
+		{"a.b", fileutil.GrepOptions{}, []int{1}},
+		{"a.b", fileutil.GrepOptions{Regexp: true}, []int{1, 2}},
+		{"a.b", fileutil.GrepOptions{IgnoreCase: true}, []int{1, 3}},
+		{"^a.b$", fileutil.GrepOptions{Regexp: true, IgnoreCase: true}, []int{1, 2, 3}},
+	} {
+		var got []int
+		for _, m := range grepLines(t, tc.pattern, text, tc.opts) {
+			got = append(got, m.LineNumber)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%q %+v: got lines %v, want %v", tc.pattern, tc.opts, got, tc.want)
+		}
+	}
+	if _, err := fileutil.NewGrepper("(", fileutil.GrepOptions{Regexp: true}); err == nil {
+		t.Error("invalid regexp accepted")
+	}
+}
+
+func TestGrepContext(t *testing.T) {
+	text := "1\nx2\n3\n4\nx5\nx6\n7\n8\n"
+	matches := grepLines(t, "x", text, fileutil.GrepOptions{Before: 2, After: 1})
+	if len(matches) != 3 {
+		t.Fatalf("got %+v", matches)
+	}
+	for i, want := range []struct {
+		line          string
+		before, after []string
+	}{
+		{"x2", []string{"1"}, []string{"3"}},
+		{"x5", []string{"3", "4"}, []string{"x6"}},
+		{"x6", []string{"4", "x5"}, []string{"7"}},
+	} {
+		m := matches[i]
+		if m.Line != want.line || !slices.Equal(m.Before, want.before) || !slices.Equal(m.After, want.after) {
+			t.Errorf("match %d: got %+v, want %+v", i, m, want)
+		}
+	}
+
+	// MaxCount stops matching but still completes the context of the last match.
+	matches = grepLines(t, "x", text, fileutil.GrepOptions{After: 2, MaxCount: 2})
+	if len(matches) != 2 || matches[1].Line != "x5" || !slices.Equal(matches[1].After, []string{"x6", "7"}) {
+		t.Errorf("MaxCount: got %+v", matches)
+	}
+}
+
+func TestGrepBinary(t *testing.T) {
+	text := "text\x00\nneedle\n"
+	matches := grepLines(t, "needle", text, fileutil.GrepOptions{})
+	if len(matches) != 1 || !matches[0].Binary || matches[0].Line != "" {
+		t.Errorf("got %+v", matches)
+	}
+	if matches := grepLines(t, "absent", text, fileutil.GrepOptions{}); len(matches) != 0 {
+		t.Errorf("got %+v", matches)
+	}
+	matches = grepLines(t, "needle", text, fileutil.GrepOptions{Binary: true})
+	if len(matches) != 1 || matches[0].Binary || matches[0].Line != "needle" || matches[0].LineNumber != 2 {
+		t.Errorf("Binary option: got %+v", matches)
+	}
+}
+
+func TestGrepFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.go":          "package a // TODO\n",
+		"b.txt":         "TODO b\n",
+		"sub/c.go":      "// TODO c\n",
+		"vendor/d.go":   "// TODO d\n",
+		"sub/clean.go":  "done\n",
+		"sub/e_test.go": "TODO e\n",
+	})
+
+	if _, err := fileutil.GrepFiles("TODO", fileutil.GrepOptions{}, root); !errors.Is(err, fileutil.ErrIsDirectory) {
+		t.Errorf("non-recursive directory: got %v", err)
+	}
+	matches, err := fileutil.GrepFiles("TODO", fileutil.GrepOptions{
+		Recursive: true,
+		Include:   []string{"*.go"},
+		Exclude:   []string{"*_test.go", "**/vendor/**"},
+	}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range matches {
+		rel, _ := filepath.Rel(root, m.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := []string{"a.go", "sub/c.go"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	missing := filepath.Join(root, "missing")
+	g, err := fileutil.NewGrepper("TODO", fileutil.GrepOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for m, err := range g.Files(missing, filepath.Join(root, "b.txt"), filepath.Join(root, "a.go")) {
+		if err != nil {
+			results = append(results, "error "+filepath.Base(m.Path))
+			continue
+		}
+		results = append(results, filepath.Base(m.Path))
+		break // stopping early must not panic or search further
+	}
+	if want := []string{"error missing", "b.txt"}; !slices.Equal(results, want) {
+		t.Errorf("got %q, want %q", results, want)
+	}
+}
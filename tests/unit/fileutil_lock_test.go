@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func TestTryLockFileExcludes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	lock, err := fileutil.TryLockFile(path, fileutil.ExclusiveLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileutil.TryLockFile(path, fileutil.SharedLock); !errors.Is(err, fileutil.ErrLocked) {
+		t.Errorf("got %v, want ErrLocked", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := fileutil.TryLockFile(path, fileutil.SharedLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Unlock()
+	b, err := fileutil.TryLockFile(path, fileutil.SharedLock)
+	if err != nil {
+		t.Fatalf("second shared lock: %v", err)
+	}
+	defer b.Unlock()
+	if _, err := fileutil.TryLockFile(path, fileutil.ExclusiveLock); !errors.Is(err, fileutil.ErrLocked) {
+		t.Errorf("got %v, want ErrLocked", err)
+	}
+}
+
+func TestLockFileWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	lock, err := fileutil.TryLockFile(path, fileutil.ExclusiveLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileutil.LockFileTimeout(path, fileutil.ExclusiveLock, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { lock.Unlock() })
+	second, err := fileutil.LockFileTimeout(path, fileutil.ExclusiveLock, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Unlock()
+}
+
+func TestAppendToFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	line := strings.Repeat("x", 1000) + "\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := fileutil.AppendToFile(path, line); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := string(readFile(t, path)); got != strings.Repeat(line, 200) {
+		t.Errorf("got %d bytes of interleaved output", len(got))
+	}
+}
+
+func TestPIDLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	lock, err := fileutil.AcquirePIDLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := fileutil.ReadPIDFile(path); err != nil || pid != os.Getpid() {
+		t.Errorf("got pid %d, %v", pid, err)
+	}
+	if _, err := fileutil.AcquirePIDLock(path); !errors.Is(err, fileutil.ErrLocked) {
+		t.Errorf("got %v, want ErrLocked", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("lockfile not removed")
+	}
+
+	// A lockfile left by a process that is gone is taken over.
+	if err := os.WriteFile(path, []byte(strconv.Itoa(1<<22+12345)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = fileutil.AcquirePIDLock(path)
+	if err != nil {
+		t.Fatalf("stale lockfile: %v", err)
+	}
+	lock.Release()
+}
+
+func TestPIDLockExcludesUnderContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	var holders, acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				lock, err := fileutil.AcquirePIDLock(path)
+				if errors.Is(err, fileutil.ErrLocked) {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if holders.Add(1) > 1 {
+					t.Error("two holders of the PID lock")
+				}
+				acquired.Add(1)
+				time.Sleep(100 * time.Microsecond)
+				holders.Add(-1)
+				if err := lock.Release(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if acquired.Load() == 0 {
+		t.Error("lock never acquired")
+	}
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/cryptoutil"
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.txt": "a", "dir/b.bin": "b", "odd\\name": "c"})
+	m, err := fileutil.BuildManifest(context.Background(), root, fileutil.WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range m.Files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"a.txt", "dir/b.bin", "odd\\name"}; !slices.Equal(paths, want) {
+		t.Fatalf("got %q, want %q", paths, want)
+	}
+	if want, _ := fileutil.CalculateFileSHA256(filepath.Join(root, "a.txt")); m.Files[0].SHA256 != want || m.Files[0].Size != 1 {
+		t.Errorf("got %+v, want digest %s", m.Files[0], want)
+	}
+
+	out := t.TempDir()
+	for _, name := range []string{"manifest.json", "SHA256SUMS"} {
+		path := filepath.Join(out, name)
+		if err := m.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := fileutil.LoadManifest(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i, f := range loaded.Files {
+			if f.Path != m.Files[i].Path || f.SHA256 != m.Files[i].SHA256 {
+				t.Errorf("%s: got %+v, want %+v", name, f, m.Files[i])
+			}
+		}
+		report, err := fileutil.VerifyManifest(context.Background(), root, loaded, fileutil.WalkOptions{})
+		if err != nil || !report.OK() {
+			t.Errorf("%s: got %+v, %v", name, report, err)
+		}
+	}
+	if sums := string(readFile(t, filepath.Join(out, "SHA256SUMS"))); !strings.Contains(sums, "\\"+m.Files[2].SHA256+"  odd\\\\name\n") {
+		t.Errorf("backslash not escaped:\n%s", sums)
+	}
+}
+
+func TestReadManifestSHA256Sums(t *testing.T) {
+	digest := strings.Repeat("Ab", 32)
+	m, err := fileutil.ReadManifest(strings.NewReader("# comment\n" + digest + " *bin/tool\r\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "bin/tool" || m.Files[0].SHA256 != strings.ToLower(digest) || m.Files[0].Size != -1 {
+		t.Errorf("got %+v", m.Files)
+	}
+	for _, bad := range []string{digest[1:] + "  x\n", strings.Repeat("g", 64) + "  x\n", digest + "x\n", digest + "  \n"} {
+		if _, err := fileutil.ReadManifest(strings.NewReader(bad)); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestVerifyManifestReportsDifferences(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"same": "s", "changed": "old", "removed": "r"})
+	m, err := fileutil.BuildManifest(context.Background(), root, fileutil.WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]string{"changed": "new", "added": "a"})
+	if err := os.Remove(filepath.Join(root, "removed")); err != nil {
+		t.Fatal(err)
+	}
+	report, err := fileutil.VerifyManifest(context.Background(), root, m, fileutil.WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || !slices.Equal(report.Missing, []string{"removed"}) || !slices.Equal(report.Extra, []string{"added"}) || !slices.Equal(report.Modified, []string{"changed"}) {
+		t.Errorf("got %+v", report)
+	}
+}
+
+func TestManifestSignature(t *testing.T) {
+	key, pub, err := cryptoutil.GenerateRSAKeys(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &fileutil.Manifest{Version: fileutil.ManifestVersion, Files: []fileutil.ManifestEntry{{Path: "a", Size: 1, SHA256: strings.Repeat("0", 64)}}}
+	if err := m.VerifySignature(pub); !errors.Is(err, fileutil.ErrManifestUnsigned) {
+		t.Errorf("unsigned: got %v", err)
+	}
+	if err := m.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := fileutil.LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.VerifySignature(pub); err != nil {
+		t.Errorf("signed: got %v", err)
+	}
+	loaded.Files[0].Size = 2
+	if err := loaded.VerifySignature(pub); err == nil {
+		t.Error("tampered manifest verified")
+	}
+}
@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func openRoot(t *testing.T, dir string) *fileutil.Root {
+	t.Helper()
+	r, err := fileutil.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRootFileOperations(t *testing.T) {
+	dir := t.TempDir()
+	r := openRoot(t, dir)
+
+	if err := r.MkdirAll("a/b", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteFile("a/b/c.txt", []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, filepath.Join(dir, "a", "b", "c.txt"))); got != "data" {
+		t.Errorf("WriteFile: got %q", got)
+	}
+	if err := r.Rename("a/b/c.txt", "a/./d.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := r.ReadFile("a/b/../d.txt"); err != nil || string(data) != "data" {
+		t.Errorf("ReadFile: got %q, %v", data, err)
+	}
+	if err := r.Symlink("../d.txt", "a/b/link"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := r.Readlink("a/b/link"); err != nil || target != "../d.txt" {
+		t.Errorf("Readlink: got %q, %v", target, err)
+	}
+	if info, err := r.Stat("a/b/link"); err != nil || info.Size() != 4 {
+		t.Errorf("Stat through a symlink: got %v, %v", info, err)
+	}
+	if info, err := r.Lstat("a/b/link"); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat: got %v, %v", info, err)
+	}
+
+	var walked []string
+	err := r.Walk(".", func(name string, d fs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	if want := []string{".", "a", "a/b", "a/b/link", "a/d.txt"}; err != nil || !slices.Equal(walked, want) {
+		t.Errorf("Walk: got %q, %v, want %q", walked, err, want)
+	}
+
+	sub, err := r.Sub("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if data, err := fs.ReadFile(sub.FS(), "d.txt"); err != nil || string(data) != "data" {
+		t.Errorf("Sub: got %q, %v", data, err)
+	}
+	if _, err := sub.Open("../a/d.txt"); !errors.Is(err, fileutil.ErrPathEscape) {
+		t.Errorf("Sub escaping to its parent: got %v", err)
+	}
+
+	for _, name := range []string{".", "a/.."} {
+		if err := r.RemoveAll(name); !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("RemoveAll(%q): got %v, want ErrInvalid", name, err)
+		}
+	}
+	if err := r.RemoveAll("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Errorf("RemoveAll: got %v", err)
+	}
+}
+
+func TestRootRejectsEscapes(t *testing.T) {
+	parent := t.TempDir()
+	writeTree(t, parent, map[string]string{"secret": "s", "base/inside": "i"})
+	r := openRoot(t, filepath.Join(parent, "base"))
+
+	for _, name := range []string{"../secret", "x/../../secret", "/etc/passwd", filepath.Join(parent, "secret")} {
+		if _, err := r.ReadFile(name); !errors.Is(err, fileutil.ErrPathEscape) {
+			t.Errorf("ReadFile(%q): got %v, want ErrPathEscape", name, err)
+		}
+		if err := r.WriteFile(name, nil, 0644); !errors.Is(err, fileutil.ErrPathEscape) {
+			t.Errorf("WriteFile(%q): got %v, want ErrPathEscape", name, err)
+		}
+	}
+	if _, err := r.Open(""); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Open(\"\"): got %v, want ErrInvalid", err)
+	}
+
+	for _, target := range []string{"../secret", "/etc/passwd", "sub/../../secret", "inside/../x"} {
+		if err := r.Symlink(target, "link"); !errors.Is(err, fileutil.ErrPathEscape) {
+			t.Errorf("Symlink(%q): got %v, want ErrPathEscape", target, err)
+		}
+	}
+
+	// A symlink planted behind the Root's back is not followed out of it.
+	if err := os.Symlink(filepath.Join(parent, "secret"), filepath.Join(parent, "base", "planted")); err != nil {
+		t.Skip(err)
+	}
+	if data, err := r.ReadFile("planted"); err == nil {
+		t.Errorf("ReadFile through an escaping symlink: got %q", data)
+	}
+	if data, err := r.ReadFile("inside"); err != nil || string(data) != "i" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func writeString(t *testing.T, w io.Writer, s string) {
+	t.Helper()
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readBackup returns the content of a backup, decompressing it if needed.
+func readBackup(t *testing.T, path string) string {
+	t.Helper()
+	if !strings.HasSuffix(path, ".gz") {
+		return string(readFile(t, path))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingWriterRotatesBySize(t *testing.T) {
+	// Glob metacharacters in the directory must not hide the backups.
+	dir := filepath.Join(t.TempDir(), "logs [x]")
+	path := filepath.Join(dir, "app.log")
+	w, err := fileutil.NewRotatingWriter(path, fileutil.RotateOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"first\n", "second\n", "third\n", "a much longer line\n"} {
+		writeString(t, w, s)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range backups {
+		if filepath.Dir(b) != dir || !strings.HasPrefix(filepath.Base(b), "app-") || filepath.Ext(b) != ".log" {
+			t.Errorf("unexpected backup name %q", b)
+		}
+		got = append(got, readBackup(t, b))
+	}
+	if want := []string{"first\n", "second\n", "third\n"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("backups: got %q, want %q", got, want)
+	}
+	// A line longer than MaxSize still goes into a single file.
+	if got := string(readFile(t, path)); got != "a much longer line\n" {
+		t.Errorf("current file: got %q", got)
+	}
+	writeTree(t, dir, map[string]string{"app-notastamp.log": "", "other-20240102T150405.000.log": ""})
+	if again, err := w.Backups(); err != nil || len(again) != len(backups) {
+		t.Errorf("unrelated files counted as backups: got %q, %v", again, err)
+	}
+}
+
+func TestRotatingWriterPrunesAndCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := fileutil.NewRotatingWriter(path, fileutil.RotateOptions{MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		writeString(t, w, strings.Repeat("x", i+1))
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got backups %q, want the 2 newest", backups)
+	}
+	for i, b := range backups {
+		if !strings.HasSuffix(b, ".log.gz") {
+			t.Errorf("%s is not compressed", b)
+		}
+		if got, want := readBackup(t, b), strings.Repeat("x", i+4); got != want {
+			t.Errorf("%s: got %q, want %q", b, got, want)
+		}
+	}
+	if err := w.Rotate(); err != os.ErrClosed {
+		t.Errorf("Rotate after Close: got %v", err)
+	}
+}
+
+func TestRotatingWriterRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := fileutil.NewRotatingWriter(path, fileutil.RotateOptions{MaxAge: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	writeString(t, w, "old\n")
+	writeString(t, w, "still young\n")
+	time.Sleep(60 * time.Millisecond)
+	writeString(t, w, "new\n")
+
+	backups, err := w.Backups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("got %q, %v", backups, err)
+	}
+	if got := readBackup(t, backups[0]); got != "old\nstill young\n" {
+		t.Errorf("backup: got %q", got)
+	}
+	if got := string(readFile(t, path)); got != "new\n" {
+		t.Errorf("current file: got %q", got)
+	}
+}
@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// entries returns an iterator over a fixed list of archive entries.
+func entries(list ...*fileutil.ArchiveEntry) fileutil.EntryIterator {
+	return fileutil.EntryIteratorFunc(func() (*fileutil.ArchiveEntry, error) {
+		if len(list) == 0 {
+			return nil, io.EOF
+		}
+		e := list[0]
+		list = list[1:]
+		return e, nil
+	})
+}
+
+func TestWriteArchiveRoundTrip(t *testing.T) {
+	for _, format := range []fileutil.ArchiveFormat{fileutil.FormatZip, fileutil.FormatTar, fileutil.FormatTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := fileutil.WriteArchive(&buf, format, entries(
+				&fileutil.ArchiveEntry{Name: "dir", Mode: os.ModeDir},
+				&fileutil.ArchiveEntry{Name: "dir/a.txt", Size: 5, Body: strings.NewReader("hello")},
+			), fileutil.StreamOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dest := t.TempDir()
+			var progress []fileutil.ArchiveProgress
+			opts := fileutil.StreamOptions{Progress: func(p fileutil.ArchiveProgress) { progress = append(progress, p) }}
+			if format == fileutil.FormatZip {
+				_, err = fileutil.UnzipReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), dest, fileutil.DefaultExtractLimits(), opts)
+			} else {
+				_, err = fileutil.UntarReader(&buf, dest, fileutil.DefaultExtractLimits(), opts)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(filepath.Join(dest, "dir"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != 0755 {
+				t.Errorf("directory mode %v, want 0755", got)
+			}
+			if got := string(readFile(t, filepath.Join(dest, "dir", "a.txt"))); got != "hello" {
+				t.Errorf("got %q", got)
+			}
+			if len(progress) != 2 || progress[1].Entries != 2 || progress[1].Bytes != 5 {
+				t.Errorf("progress %+v", progress)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+// chmodTree sets the permissions of the given paths, relative to dir, and restores write access at
+// the end of the test so that the temporary directory can be removed.
+func chmodTree(t *testing.T, dir string, modes map[string]os.FileMode) {
+	t.Helper()
+	for name, mode := range modes {
+		if err := os.Chmod(filepath.Join(dir, filepath.FromSlash(name)), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				os.Chmod(p, 0755)
+			}
+			return nil
+		})
+	})
+}
+
+func TestSyncDirCopiesModesAndConverges(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a", "ro/b.txt": "b", "ro/sub/c.txt": "c"})
+	modes := map[string]os.FileMode{"a.txt": 0666, "ro/b.txt": 0640, "ro/sub": 0555, "ro": 0555}
+	chmodTree(t, src, modes)
+	dst := filepath.Join(t.TempDir(), "dst")
+	chmodTree(t, dst, nil) // dst is created read-only below
+
+	plan, err := fileutil.SyncDir(src, dst, fileutil.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := plan.Count(fileutil.SyncCreate); got != 5 {
+		t.Errorf("first sync: %d creations, want 5\n%s", got, plan)
+	}
+	for name, mode := range modes {
+		if got := fileMode(t, filepath.Join(dst, filepath.FromSlash(name))); got != mode {
+			t.Errorf("%s: got mode %v, want %v", name, got, mode)
+		}
+	}
+	if got := string(readFile(t, filepath.Join(dst, "ro", "sub", "c.txt"))); got != "c" {
+		t.Errorf("got %q", got)
+	}
+
+	plan, err = fileutil.SyncDir(src, dst, fileutil.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := plan.Count(fileutil.SyncSkip); got != len(plan.Ops) {
+		t.Errorf("second sync is not a no-op:\n%s", plan)
+	}
+}
+
+func TestSyncDirUpdatesInsideReadOnlyDirectories(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"ro/b.txt": "b"})
+	dst := filepath.Join(t.TempDir(), "dst")
+	if _, err := fileutil.SyncDir(src, dst, fileutil.SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, src, map[string]string{"ro/b.txt": "changed", "ro/new.txt": "new"})
+	chmodTree(t, src, map[string]os.FileMode{"ro": 0555})
+	chmodTree(t, dst, map[string]os.FileMode{"ro": 0555})
+
+	plan, err := fileutil.SyncDir(src, dst, fileutil.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := plan.String(); got != "~ ro/b.txt\n+ ro/new.txt\n" {
+		t.Errorf("got plan\n%s", got)
+	}
+	if got := string(readFile(t, filepath.Join(dst, "ro", "b.txt"))); got != "changed" {
+		t.Errorf("got %q", got)
+	}
+	if got := fileMode(t, filepath.Join(dst, "ro")); got != 0555 {
+		t.Errorf("got mode %v, want 0555", got)
+	}
+}
+
+func TestSyncDirDelete(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTree(t, src, map[string]string{"keep.txt": "k"})
+	writeTree(t, dst, map[string]string{"keep.txt": "old", "extra.txt": "x", "gone/ro/y.txt": "y", "skip.log": "s"})
+	chmodTree(t, dst, map[string]os.FileMode{"gone/ro": 0555})
+	opts := fileutil.SyncOptions{Delete: true, Exclude: []string{"*.log"}, DryRun: true}
+
+	plan, err := fileutil.SyncDir(src, dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "~ keep.txt\n- extra.txt\n- gone/\n"; plan.String() != want {
+		t.Errorf("got plan\n%s\nwant\n%s", plan, want)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "extra.txt")); err != nil {
+		t.Errorf("dry run changed the destination: %v", err)
+	}
+
+	opts.DryRun = false
+	if _, err := fileutil.SyncDir(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"extra.txt", "gone"} {
+		if _, err := os.Lstat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("%s: got %v, want it deleted", name, err)
+		}
+	}
+	if got := string(readFile(t, filepath.Join(dst, "skip.log"))); got != "s" {
+		t.Errorf("excluded file: got %q", got)
+	}
+	if got := string(readFile(t, filepath.Join(dst, "keep.txt"))); got != "k" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func startTail(t *testing.T, path string, opts fileutil.TailOptions) (*fileutil.Tail, context.CancelFunc) {
+	t.Helper()
+	opts.PollInterval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	tail, err := fileutil.TailFile(ctx, path, opts)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		tail.Err()
+	})
+	return tail, cancel
+}
+
+// expectLines waits for the given lines from tail, in order.
+func expectLines(t *testing.T, tail *fileutil.Tail, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case line, ok := <-tail.Lines:
+			if !ok {
+				t.Fatalf("tail stopped waiting for %q: %v", w, tail.Err())
+			}
+			if line.Text != w {
+				t.Fatalf("got %q, want %q", line.Text, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTailStartPositions(t *testing.T) {
+	path := writeTemp(t, []byte("1\n2\n3\n"))
+	for _, tc := range []struct {
+		opts fileutil.TailOptions
+		want []string
+	}{
+		{fileutil.TailOptions{FromStart: true}, []string{"1", "2", "3", "4"}},
+		{fileutil.TailOptions{Lines: 2}, []string{"2", "3", "4"}},
+		{fileutil.TailOptions{Offset: 4}, []string{"3", "4"}},
+		{fileutil.TailOptions{}, []string{"4"}},
+	} {
+		tail, cancel := startTail(t, path, tc.opts)
+		appendFile(t, path, "4\n")
+		expectLines(t, tail, tc.want...)
+		cancel()
+		if err := tail.Err(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Err: got %v", err)
+		}
+		if err := os.WriteFile(path, []byte("1\n2\n3\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTailFollowsRotationAndTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	writeTree(t, filepath.Dir(path), map[string]string{"app.log": "start\n"})
+	tail, _ := startTail(t, path, fileutil.TailOptions{FromStart: true})
+	expectLines(t, tail, "start")
+
+	// logrotate: the old file is renamed, its last line still being written, and a new one created.
+	appendFile(t, path, "old end")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new\n")
+	expectLines(t, tail, "old end", "new")
+
+	// copytruncate
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "after truncate\n")
+	expectLines(t, tail, "after truncate")
+}
+
+func TestTailDetectsTruncationFollowedByGrowth(t *testing.T) {
+	path := writeTemp(t, []byte("aaaa\n"))
+	tail, _ := startTail(t, path, fileutil.TailOptions{FromStart: true})
+	expectLines(t, tail, "aaaa")
+
+	// Truncated and rewritten past the old offset between two polls: the size never drops.
+	if err := os.WriteFile(path, []byte("bbbbbbbb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, tail, "bbbbbbbb", "c")
+}
+
+func TestTailOffsetFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	offsets := filepath.Join(dir, "app.offset")
+	writeTree(t, dir, map[string]string{"app.log": "1\n2\n"})
+	opts := fileutil.TailOptions{OffsetFile: offsets, FromStart: true}
+
+	tail, cancel := startTail(t, path, opts)
+	expectLines(t, tail, "1", "2")
+	time.Sleep(20 * time.Millisecond)
+	before, err := os.Stat(offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // several polls without new data
+	after, err := os.Stat(offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("the offset file was rewritten although the offset did not change")
+	}
+	cancel()
+	tail.Err()
+
+	// Resumed after the lines already read.
+	appendFile(t, path, "3\n")
+	tail, cancel = startTail(t, path, opts)
+	expectLines(t, tail, "3")
+	cancel()
+	tail.Err()
+
+	// A rotated file with the same content is a different file and is read from the start.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "1\n2\n3\n")
+	tail, _ = startTail(t, path, opts)
+	expectLines(t, tail, "1", "2", "3")
+}
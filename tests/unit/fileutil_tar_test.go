@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+type tarEntry struct {
+	header *tar.Header
+	body   string
+}
+
+func writeTar(t *testing.T, compress bool, entries ...tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+	for _, e := range entries {
+		e.header.Size = int64(len(e.body))
+		if err := tw.WriteHeader(e.header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return writeTemp(t, buf.Bytes())
+}
+
+func tarFile(name, body string, mode int64) tarEntry {
+	return tarEntry{&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, ModTime: time.Unix(1700000000, 0)}, body}
+}
+
+func tarDir(name string, mode int64) tarEntry {
+	return tarEntry{header: &tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: mode}}
+}
+
+func TestUntarSkipsGlobalHeader(t *testing.T) {
+	// git archive starts every tarball with a pax global header holding the commit ID.
+	global := tarEntry{header: &tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "0123abcd"}}}
+	for _, compress := range []bool{false, true} {
+		src := writeTar(t, compress, global, tarDir("repo/", 0755), tarFile("repo/README", "hi", 0644))
+		dest := t.TempDir()
+		files, err := fileutil.Untar(src, dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 2 {
+			t.Errorf("got %v, want 2 entries", files)
+		}
+		if got := string(readFile(t, filepath.Join(dest, "repo", "README"))); got != "hi" {
+			t.Errorf("got %q", got)
+		}
+	}
+}
+
+func TestUntarPreservesModes(t *testing.T) {
+	src := writeTar(t, false,
+		tarDir("ro/", 0555),
+		tarFile("ro/shared", "x", 0666),
+		tarFile("script.sh", "#!/bin/sh", 0750),
+		tarFile("existing", "new", 0600),
+	)
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "existing"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileutil.Untar(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(dest, "ro"), 0755) })
+
+	for name, want := range map[string]os.FileMode{
+		"ro":        0555,
+		"ro/shared": 0666, // group and other write survive the umask
+		"script.sh": 0750,
+		"existing":  0600,
+	} {
+		info, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: got mode %v, want %v", name, got, want)
+		}
+	}
+	if got := string(readFile(t, filepath.Join(dest, "existing"))); got != "new" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUntarRejectsTraversal(t *testing.T) {
+	parent := t.TempDir()
+	_, err := fileutil.Untar(writeTar(t, true, tarFile("../evil.txt", "x", 0644)), filepath.Join(parent, "dest"))
+	wantRule(t, err, fileutil.RulePathTraversal)
+	if _, err := os.Stat(filepath.Join(parent, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("file written outside the destination")
+	}
+}
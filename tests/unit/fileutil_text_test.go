@@ -0,0 +1,202 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func writeTemp(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestConvertUnixToDos(t *testing.T) {
+	path := writeTemp(t, []byte("a\nb\r\nc\n\nd"))
+	if err := fileutil.ConvertUnixToDos(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(readFile(t, path)), "a\r\nb\r\nc\r\n\r\nd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("permissions changed to %v", info.Mode().Perm())
+	}
+}
+
+func TestConvertDosToUnix(t *testing.T) {
+	path := writeTemp(t, []byte("a\r\nb\rc\r\n\r\nd\r"))
+	if err := fileutil.ConvertDosToUnix(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(readFile(t, path)), "a\nb\rc\n\nd\r"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLineEndingsAcrossReads(t *testing.T) {
+	// One byte per Read puts every CR and LF on a read boundary.
+	var dos bytes.Buffer
+	if err := fileutil.UnixToDos(&dos, iotest.OneByteReader(strings.NewReader("x\r\ny\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if dos.String() != "x\r\ny\r\n" {
+		t.Errorf("UnixToDos = %q", dos.String())
+	}
+	var unix bytes.Buffer
+	if err := fileutil.DosToUnix(&unix, iotest.OneByteReader(&dos)); err != nil {
+		t.Fatal(err)
+	}
+	if unix.String() != "x\ny\n" {
+		t.Errorf("DosToUnix = %q", unix.String())
+	}
+}
+
+func TestDetectBOM(t *testing.T) {
+	tests := []struct {
+		input []byte
+		enc   fileutil.TextEncoding
+		rest  string
+	}{
+		{[]byte("\xEF\xBB\xBFhi"), fileutil.EncodingUTF8, "hi"},
+		{[]byte("\xFF\xFEh\x00"), fileutil.EncodingUTF16LE, "h\x00"},
+		{[]byte("\xFE\xFF\x00h"), fileutil.EncodingUTF16BE, "\x00h"},
+		{[]byte("hi"), fileutil.EncodingUnknown, "hi"},
+		{nil, fileutil.EncodingUnknown, ""},
+	}
+	for _, tt := range tests {
+		enc, r, err := fileutil.DetectBOM(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rest bytes.Buffer
+		rest.ReadFrom(r)
+		if enc != tt.enc || rest.String() != tt.rest {
+			t.Errorf("DetectBOM(%q) = %v, %q; want %v, %q", tt.input, enc, rest.String(), tt.enc, tt.rest)
+		}
+	}
+}
+
+func TestStripBOM(t *testing.T) {
+	path := writeTemp(t, []byte("\xEF\xBB\xBFhello"))
+	if err := fileutil.StripBOM(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, path)); got != "hello" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func encodeUTF16(s string, bigEndian bool) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+func TestConvertToUTF8(t *testing.T) {
+	const text = "héllo wörld 😀\n"
+	for _, bigEndian := range []bool{false, true} {
+		bom := []byte{0xFF, 0xFE}
+		if bigEndian {
+			bom = []byte{0xFE, 0xFF}
+		}
+		path := writeTemp(t, append(bom, encodeUTF16(text, bigEndian)...))
+		if err := fileutil.ConvertToUTF8(path); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(readFile(t, path)); got != text {
+			t.Errorf("bigEndian=%v: got %q, want %q", bigEndian, got, text)
+		}
+	}
+}
+
+func TestUTF16ReaderInvalid(t *testing.T) {
+	// Lone high surrogate followed by 'a', lone low surrogate, then an odd trailing byte.
+	input := []byte{0x00, 0xD8, 'a', 0x00, 0x00, 0xDC, 'b'}
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(iotest.OneByteReader(fileutil.NewUTF16Reader(bytes.NewReader(input), false))); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "�a��"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBase64Files(t *testing.T) {
+	data := make([]byte, 1<<20+7)
+	rand.Read(data)
+	src := writeTemp(t, data)
+	dir := t.TempDir()
+	encoded := filepath.Join(dir, "encoded")
+	decoded := filepath.Join(dir, "decoded")
+
+	if err := fileutil.EncodeBase64File(src, encoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, encoded)); got != base64.StdEncoding.EncodeToString(data) {
+		t.Fatal("EncodeBase64File output differs from encoding/base64")
+	}
+	if err := fileutil.DecodeBase64File(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readFile(t, decoded), data) {
+		t.Error("DecodeBase64File did not round-trip")
+	}
+}
+
+func TestEncodeFileToBase64(t *testing.T) {
+	path := writeTemp(t, []byte("hello, world"))
+	got, err := fileutil.EncodeFileToBase64(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "aGVsbG8sIHdvcmxk" {
+		t.Errorf("got %q", got)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := fileutil.DecodeBase64ToFile("aGVsbG8s\nIHdvcmxk", out); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, out)); got != "hello, world" {
+		t.Errorf("DecodeBase64ToFile wrote %q", got)
+	}
+
+	if err := fileutil.DecodeBase64ToFile("not base64!", out); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+	if got := string(readFile(t, out)); got != "hello, world" {
+		t.Errorf("failed decode replaced the file with %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func openTrash(t *testing.T, dir string) *fileutil.Trash {
+	t.Helper()
+	trash, err := fileutil.OpenTrash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trash
+}
+
+func TestDeleteHelpersToTrash(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c", "dir/d.txt": "d", "full/e.txt": "e"})
+	trash := openTrash(t, t.TempDir())
+	to := fileutil.ToTrash(trash)
+
+	if err := fileutil.DeleteFile(filepath.Join(root, "a.txt"), to); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.BatchRemoveFiles([]string{filepath.Join(root, "b.txt"), filepath.Join(root, "c.txt")}, to); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.RemoveDirectory(filepath.Join(root, "dir"), to); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.RemoveDirectory(filepath.Join(root, "missing"), to); err != nil {
+		t.Errorf("removing a missing directory: %v", err)
+	}
+	if err := fileutil.RemoveContents(filepath.Join(root, "full"), to); err != nil {
+		t.Fatal(err)
+	}
+
+	left, err := fileutil.ListFilesRecursive(root)
+	if err != nil || len(left) != 0 {
+		t.Errorf("left behind: %q, %v", left, err)
+	}
+	items, err := trash.List()
+	if err != nil || len(items) != 5 {
+		t.Fatalf("got %d items, %v", len(items), err)
+	}
+	for _, item := range items {
+		if err := trash.Restore(item.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := string(readFile(t, filepath.Join(root, "dir", "d.txt"))); got != "d" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTrashListSkipsCorruptRecords(t *testing.T) {
+	dir := t.TempDir()
+	trash := openTrash(t, dir)
+	path := writeTemp(t, []byte("data"))
+	item, err := trash.Move(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "info", "bad.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := trash.List()
+	if err == nil {
+		t.Error("corrupt record not reported")
+	}
+	if len(items) != 1 || items[0].ID != item.ID {
+		t.Errorf("got %+v", items)
+	}
+
+	result, err := trash.Purge(fileutil.RetentionPolicy{MaxTotalSize: 1})
+	if err == nil || result == nil || len(result.Removed) != 1 {
+		t.Errorf("got %+v, %v", result, err)
+	}
+}
+
+func TestSweepSkipsTrashBelowDir(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"old.log": "x"})
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "old.log"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	trash := openTrash(t, filepath.Join(root, ".trash"))
+
+	for i := 0; i < 2; i++ {
+		result, err := fileutil.Sweep(root, fileutil.RetentionPolicy{MaxAge: time.Hour, Trash: trash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 1 - i; len(result.Removed) != want {
+			t.Errorf("sweep %d: removed %+v, want %d", i, result.Removed, want)
+		}
+	}
+	if items, err := trash.List(); err != nil || len(items) != 1 {
+		t.Errorf("got %+v, %v", items, err)
+	}
+}
+
+func TestTrashMoveAcrossFilesystemsIsLossless(t *testing.T) {
+	// /dev/shm is usually a tmpfs, unlike the temporary directory, so moves into it must copy.
+	trashDir, err := os.MkdirTemp("/dev/shm", "trash")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(trashDir) })
+	trash := openTrash(t, trashDir)
+
+	root := t.TempDir()
+	tree := filepath.Join(root, "tree")
+	writeTree(t, tree, map[string]string{"secret.txt": "s", "sub/target.txt": "t"})
+	if err := os.Symlink("sub/target.txt", filepath.Join(tree, "link")); err != nil {
+		t.Skip(err)
+	}
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for name, mode := range map[string]os.FileMode{"secret.txt": 0600, "sub": 0750} {
+		p := filepath.Join(tree, name)
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := trash.Move(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(tree); !os.IsNotExist(err) {
+		t.Fatalf("source left behind: %v", err)
+	}
+	if err := trash.Restore(item.ID); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{"secret.txt": 0600, "sub": os.ModeDir | 0750} {
+		info, err := os.Lstat(filepath.Join(tree, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != mode || !info.ModTime().Equal(old) {
+			t.Errorf("%s: got %v %v, want %v %v", name, info.Mode(), info.ModTime(), mode, old)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(tree, "link")); err != nil || target != "sub/target.txt" {
+		t.Errorf("symlink: got %q, %v", target, err)
+	}
+}
+
+func TestRemoveContentsKeepsTrashInside(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.txt": "a", "var/b.txt": "b"})
+	trash := openTrash(t, filepath.Join(root, "var", ".trash"))
+
+	if err := fileutil.RemoveContents(root, fileutil.ToTrash(trash)); err != nil {
+		t.Fatal(err)
+	}
+	left, err := fileutil.FindFiles(root, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range left {
+		rel, _ := filepath.Rel(root, p)
+		if rel != "." && rel != "var" && !strings.HasPrefix(rel, filepath.Join("var", ".trash")) {
+			t.Errorf("left behind: %s", rel)
+		}
+	}
+	items, err := trash.List()
+	if err != nil || len(items) != 2 {
+		t.Fatalf("got %+v, %v", items, err)
+	}
+	if _, err := trash.Move(filepath.Join(root, "var")); err == nil {
+		t.Error("moved a directory into the trash it contains")
+	}
+}
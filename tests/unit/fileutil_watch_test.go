@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-infrastructure/pkg/util/fileutil"
+)
+
+func newWatcher(t *testing.T, opts fileutil.WatchOptions, paths ...string) *fileutil.Watcher {
+	t.Helper()
+	w, err := fileutil.NewWatcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	for _, p := range paths {
+		if err := w.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+// waitEvent reads events until one for path includes op.
+func waitEvent(t *testing.T, w *fileutil.Watcher, path string, op fileutil.Op) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events:
+			if ev.Path == path && ev.Op&op != 0 {
+				return
+			}
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("no %v event for %s", op, path)
+		}
+	}
+}
+
+func TestWatcherReportsChanges(t *testing.T) {
+	for _, opts := range []fileutil.WatchOptions{
+		{Recursive: true},
+		{Recursive: true, ForcePolling: true, PollInterval: 20 * time.Millisecond},
+	} {
+		root := t.TempDir()
+		w := newWatcher(t, opts, root)
+
+		sub := filepath.Join(root, "sub")
+		if err := os.Mkdir(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, sub, fileutil.OpCreate)
+		file := filepath.Join(sub, "a.txt")
+		if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, fileutil.OpCreate|fileutil.OpWrite)
+		if err := os.WriteFile(file, []byte("longer"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, fileutil.OpWrite)
+		if err := os.Remove(file); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, fileutil.OpRemove)
+	}
+}
+
+func TestWatcherSingleFileSurvivesReplace(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := newWatcher(t, fileutil.WatchOptions{}, file)
+	for i := 0; i < 2; i++ {
+		if err := fileutil.WriteFileAtomic(file, []byte(`{"n":1}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, fileutil.OpCreate)
+	}
+}
+
+func TestWatcherDropsDirectoriesMovedOut(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w := newWatcher(t, fileutil.WatchOptions{Recursive: true}, root)
+
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(sub, moved); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, sub, fileutil.OpRename)
+	if err := os.WriteFile(filepath.Join(moved, "late.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(root, "marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Events arrive in order, so anything about the moved tree would come before the marker.
+	for {
+		select {
+		case ev := <-w.Events:
+			if ev.Path == marker {
+				return
+			}
+			t.Errorf("event for a directory moved out of the tree: %v %s", ev.Op, ev.Path)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event for the marker")
+		}
+	}
+}
+
+func TestOpString(t *testing.T) {
+	if got := (fileutil.OpCreate | fileutil.OpWrite).String(); got != "CREATE|WRITE" {
+		t.Errorf("got %q", got)
+	}
+	if got := fileutil.Op(0).String(); got != "NONE" {
+		t.Errorf("got %q", got)
+	}
+}